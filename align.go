@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// openPattern reads a pattern from a file or, if there is no such file,
+// from the catalog.
+func openPattern(name string) (*Pattern, error) {
+	if _, err := os.Stat(name); err == nil {
+		return ReadPattern(name, defaultLiveBlock)
+	}
+	return catalogPattern(name)
+}
+
+// patternUniverse returns a universe holding p with its board's top-left
+// corner at the origin.
+func patternUniverse(p *Pattern) (*Universe, error) {
+	u, err := NewUniverse(p.Rule, 1)
+	if err != nil {
+		return nil, err
+	}
+	return u, u.Load(p.Board, 0, 0)
+}
+
+// Alignment is a placement of one pattern over another: the second pattern
+// advanced Phase generations and moved by (DX, DY), leaving Diff cells that
+// are alive in one but not the other.
+type Alignment struct {
+	Phase  int
+	DX, DY int64
+	Diff   int
+}
+
+// Align finds the placements of b, advanced up to phases-1 generations
+// under its rule, that best match a, best first. Only translations that
+// make at least one live cell coincide are considered.
+func Align(a, b *Pattern, phases int) ([]Alignment, error) {
+	ua, err := patternUniverse(a)
+	if err != nil {
+		return nil, err
+	}
+	target := ua.cells()
+	ub, err := patternUniverse(b)
+	if err != nil {
+		return nil, err
+	}
+	var best []Alignment
+	for phase := 0; phase < phases; phase++ {
+		if phase > 0 {
+			if err := ub.Advance(1); err != nil {
+				return nil, err
+			}
+		}
+		// Count, for every translation, the live cells of b it lands on
+		// live cells of a.
+		cells := ub.cells()
+		overlap := map[[2]int64]int{}
+		for _, c := range cells {
+			for _, t := range target {
+				overlap[[2]int64{t[0] - c[0], t[1] - c[1]}]++
+			}
+		}
+		for d, n := range overlap {
+			best = append(best, Alignment{phase, d[0], d[1], len(target) + len(cells) - 2*n})
+		}
+	}
+	sort.Slice(best, func(i, j int) bool {
+		x, y := best[i], best[j]
+		if x.Diff != y.Diff {
+			return x.Diff < y.Diff
+		}
+		if x.Phase != y.Phase {
+			return x.Phase < y.Phase
+		}
+		if x.DY != y.DY {
+			return x.DY < y.DY
+		}
+		return x.DX < y.DX
+	})
+	return best, nil
+}
+
+// period returns the period of p run under its rule, counting only
+// patterns that return to the same cells in the same place, along with the
+// bounding box of every cell it covers over one period. It gives up after
+// limit generations.
+func period(p *Pattern, limit int) (int, [4]int64, error) {
+	u, err := patternUniverse(p)
+	if err != nil {
+		return 0, [4]int64{}, err
+	}
+	type key struct {
+		hash uint64
+		x, y int64
+	}
+	seen := map[key]int{}
+	var box [4]int64 // x0, y0, x1, y1, exclusive
+	for gen := 0; gen <= limit; gen++ {
+		x, y, w, h := u.Bounds()
+		if gen == 0 {
+			box = [4]int64{x, y, x + w, y + h}
+		}
+		box = [4]int64{min(box[0], x), min(box[1], y), max(box[2], x+w), max(box[3], y+h)}
+		k := key{u.Board(x, y, int(w), int(h)).Hash(), x, y}
+		if g, ok := seen[k]; ok {
+			return gen - g, box, nil
+		}
+		seen[k] = gen
+		if err := u.Advance(1); err != nil {
+			return 0, box, err
+		}
+	}
+	return 0, box, fmt.Errorf("pattern does not repeat within %d generations", limit)
+}
+
+// LaneHit is the result of a glider on one lane and timing meeting a
+// target.
+type LaneHit struct {
+	Lane, Timing int
+	Outcome      string
+}
+
+// GliderLanes tries a glider travelling south-east on every lane that comes
+// near target, at every timing, and describes what happens when they meet.
+// A glider's lane is x-y of the top-left corner of its phase 0 bounding
+// box, in the target's coordinates; its timing is the number of
+// generations it was run before the target started. Running a glider on
+// does not take it off its lane, so only timings modulo the target's period
+// differ. Lanes on which the glider passes by untouched are left out.
+func GliderLanes(target *Pattern, settle int) ([]LaneHit, error) {
+	glider, err := catalogPattern("glider")
+	if err != nil {
+		return nil, err
+	}
+	p, box, err := period(target, 1024)
+	if err != nil {
+		return nil, err
+	}
+	timings := p
+	gb := glider.Board
+	var hits []LaneHit
+	for lane := box[0] - box[3] - int64(gb.h) - 1; lane <= box[2]-box[1]+int64(gb.w)+1; lane++ {
+		// Start the glider two cells clear of the target's envelope.
+		gy := min(box[1], box[0]-lane) - int64(gb.h) - 2 - int64(timings/4+1)
+		gx := lane + gy
+		// It has reached the far side of the envelope by then.
+		gens := 4*(max(box[2]-gx, box[3]-gy)+2) + int64(settle)
+		for t := 0; t < timings; t++ {
+			outcome, err := laneOutcome(target, glider, target.Rule, gx, gy, t, gens)
+			if err != nil {
+				return nil, err
+			}
+			if outcome != "" {
+				hits = append(hits, LaneHit{int(lane), t, outcome})
+			}
+		}
+	}
+	return hits, nil
+}
+
+// laneOutcome runs target together with glider placed at (gx, gy) and
+// already run timing generations, for gens generations, and describes the
+// result, or returns "" if the two never met.
+func laneOutcome(target, glider *Pattern, rule Rule, gx, gy int64, timing int, gens int64) (string, error) {
+	both, err := patternUniverse(target)
+	if err != nil {
+		return "", err
+	}
+	g, err := NewUniverse(rule, 1)
+	if err != nil {
+		return "", err
+	}
+	if err := g.Load(glider.Board, gx, gy); err != nil {
+		return "", err
+	}
+	if err := g.Advance(int64(timing)); err != nil {
+		return "", err
+	}
+	for _, c := range g.cells() {
+		if err := both.Set(c[0], c[1], true); err != nil {
+			return "", err
+		}
+	}
+	alone, err := patternUniverse(target)
+	if err != nil {
+		return "", err
+	}
+	for _, u := range []*Universe{both, g, alone} {
+		if err := u.Advance(gens); err != nil {
+			return "", err
+		}
+	}
+
+	// The glider missed if the result is exactly the two run separately.
+	gc, ac := g.cells(), alone.cells()
+	if both.Population() == int64(len(gc)+len(ac)) {
+		missed := true
+		for _, c := range append(gc, ac...) {
+			if !both.Get(c[0], c[1]) {
+				missed = false
+				break
+			}
+		}
+		if missed {
+			return "", nil
+		}
+	}
+	if both.Population() == 0 {
+		return "dies out", nil
+	}
+	x, y, w, h := both.Bounds()
+	seen := map[uint64]int{}
+	for i := 0; i < 256; i++ {
+		if w > 1<<12 || h > 1<<12 {
+			break
+		}
+		k := both.Board(x, y, int(w), int(h)).Hash()
+		if j, ok := seen[k]; ok {
+			return fmt.Sprintf("%d cells, period %d", both.Population(), i-j), nil
+		}
+		seen[k] = i
+		if err := both.Advance(1); err != nil {
+			return "", err
+		}
+		x, y, w, h = both.Bounds()
+	}
+	return fmt.Sprintf("%d cells, unsettled", both.Population()), nil
+}
+
+func runAlign(args []string) error {
+	var (
+		a, b   string
+		phases int
+		top    int
+		lanes  bool
+		settle int
+	)
+	fs := flag.NewFlagSet("align", flag.ExitOnError)
+	fs.StringVar(&a, "a", "", "pattern to match: a file or a catalog pattern")
+	fs.StringVar(&b, "b", "", "pattern to align with it: a file or a catalog pattern")
+	fs.IntVar(&phases, "phases", 4, "generations of -b to try")
+	fs.IntVar(&top, "top", 5, "number of alignments to list")
+	fs.BoolVar(&lanes, "lanes", false, "list the glider lanes and timings that hit -a instead")
+	fs.IntVar(&settle, "settle", 512, "with -lanes, generations to let a reaction settle")
+	fs.Parse(args)
+	if a == "" {
+		return errors.New("align needs -a")
+	}
+	pa, err := openPattern(a)
+	if err != nil {
+		return err
+	}
+
+	if lanes {
+		hits, err := GliderLanes(pa, settle)
+		if err != nil {
+			return err
+		}
+		for _, h := range hits {
+			fmt.Printf("lane %4d, timing %d: %s\n", h.Lane, h.Timing, h.Outcome)
+		}
+		fmt.Printf("%d lane and timing combinations hit %s\n", len(hits), a)
+		return nil
+	}
+
+	if b == "" {
+		return errors.New("align needs -b, or -lanes")
+	}
+	pb, err := openPattern(b)
+	if err != nil {
+		return err
+	}
+	if pa.Rule != pb.Rule {
+		fmt.Printf("running %s under its rule, %s\n", b, pb.Rule)
+	}
+	best, err := Align(pa, pb, phases)
+	if err != nil {
+		return err
+	}
+	for _, al := range best[:min(top, len(best))] {
+		fmt.Printf("phase %d, offset (%+d, %+d): %d cells differ\n", al.Phase, al.DX, al.DY, al.Diff)
+	}
+	return nil
+}
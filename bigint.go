@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BigInt is an integer of any size. Values that fit in an int64 are held
+// directly, so arithmetic on them costs a few instructions and no
+// allocation; only larger ones fall back to math/big. The zero value is 0.
+// BigInts are values: operations return a new BigInt and never change
+// their operands.
+type BigInt struct {
+	n int64
+	b *big.Int // set only when the value does not fit in n
+}
+
+// NewBigInt returns n as a BigInt.
+func NewBigInt(n int64) BigInt {
+	return BigInt{n: n}
+}
+
+// bigOf returns b as a BigInt, taking ownership of b.
+func bigOf(b *big.Int) BigInt {
+	if b.IsInt64() {
+		return BigInt{n: b.Int64()}
+	}
+	return BigInt{b: b}
+}
+
+// pow2 returns 2^k.
+func pow2(k uint) BigInt {
+	if k < 63 {
+		return BigInt{n: 1 << k}
+	}
+	return BigInt{b: new(big.Int).Lsh(big.NewInt(1), k)}
+}
+
+// Big returns the value as a new big.Int.
+func (a BigInt) Big() *big.Int {
+	if a.b != nil {
+		return new(big.Int).Set(a.b)
+	}
+	return big.NewInt(a.n)
+}
+
+// Int64 returns the value and whether it fits in an int64. If it does not,
+// the low 64 bits are returned.
+func (a BigInt) Int64() (int64, bool) {
+	if a.b != nil {
+		return a.b.Int64(), false
+	}
+	return a.n, true
+}
+
+// Add returns a+c.
+func (a BigInt) Add(c BigInt) BigInt {
+	if a.b == nil && c.b == nil {
+		s := a.n + c.n
+		// Overflow occurred only if both operands differ in sign from s.
+		if (a.n^s)&(c.n^s) >= 0 {
+			return BigInt{n: s}
+		}
+	}
+	return bigOf(new(big.Int).Add(a.Big(), c.Big()))
+}
+
+// Sub returns a-c.
+func (a BigInt) Sub(c BigInt) BigInt {
+	if a.b == nil && c.b == nil {
+		s := a.n - c.n
+		if (a.n^c.n)&(a.n^s) >= 0 {
+			return BigInt{n: s}
+		}
+	}
+	return bigOf(new(big.Int).Sub(a.Big(), c.Big()))
+}
+
+// Cmp returns -1, 0 or +1 as a is less than, equal to or greater than c.
+func (a BigInt) Cmp(c BigInt) int {
+	if a.b == nil && c.b == nil {
+		switch {
+		case a.n < c.n:
+			return -1
+		case a.n > c.n:
+			return 1
+		}
+		return 0
+	}
+	return a.Big().Cmp(c.Big())
+}
+
+// Sign returns -1, 0 or +1 as a is negative, zero or positive.
+func (a BigInt) Sign() int {
+	if a.b != nil {
+		return a.b.Sign()
+	}
+	return a.Cmp(BigInt{})
+}
+
+// String returns the value in decimal.
+func (a BigInt) String() string {
+	if a.b != nil {
+		return a.b.String()
+	}
+	return strconv.FormatInt(a.n, 10)
+}
+
+// Display returns the value for people to read: in decimal with its digits
+// grouped in threes while that is at most 15 digits, and in scientific
+// notation beyond.
+func (a BigInt) Display() string {
+	s := a.String()
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	if len(s) > 15 {
+		return fmt.Sprintf("%s%c.%s×10^%d", sign, s[0], s[1:5], len(s)-1)
+	}
+	var out strings.Builder
+	out.WriteString(sign)
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			out.WriteByte(',')
+		}
+		out.WriteRune(c)
+	}
+	return out.String()
+}
+
+// ParseBigInt parses a decimal integer, or a power written as b^e such as
+// 2^100.
+func ParseBigInt(s string) (BigInt, error) {
+	s = strings.TrimSpace(s)
+	if base, exp, ok := strings.Cut(s, "^"); ok {
+		b, err1 := ParseBigInt(base)
+		e, err2 := strconv.ParseUint(strings.TrimSpace(exp), 10, 16)
+		if err1 != nil || err2 != nil {
+			return BigInt{}, fmt.Errorf("malformed power %q", s)
+		}
+		return bigOf(new(big.Int).Exp(b.Big(), new(big.Int).SetUint64(e), nil)), nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return BigInt{n: n}, nil
+	}
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return BigInt{}, fmt.Errorf("malformed integer %q", s)
+	}
+	return bigOf(b), nil
+}
+
+// Set parses s into a, so that a *BigInt can be used as a flag.Value.
+func (a *BigInt) Set(s string) error {
+	v, err := ParseBigInt(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// satAdd returns a+b for non-negative a and b, or math.MaxInt64 if the sum
+// does not fit.
+func satAdd(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
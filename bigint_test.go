@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// TestBigIntArith checks Add and Sub against math/big around the edges of
+// int64, where they must fall back to big.Int, and back again when the
+// result fits.
+func TestBigIntArith(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+	values := []BigInt{
+		NewBigInt(0), NewBigInt(1), NewBigInt(-1),
+		NewBigInt(math.MaxInt64), NewBigInt(math.MaxInt64 - 1),
+		NewBigInt(math.MinInt64), NewBigInt(math.MinInt64 + 1),
+		bigOf(new(big.Int).Add(big.NewInt(math.MaxInt64), big.NewInt(1))),
+		bigOf(new(big.Int).Sub(big.NewInt(math.MinInt64), big.NewInt(1))),
+		bigOf(huge), bigOf(new(big.Int).Neg(huge)),
+	}
+	for _, a := range values {
+		for _, c := range values {
+			for _, op := range []struct {
+				name string
+				got  BigInt
+				want *big.Int
+			}{
+				{"+", a.Add(c), new(big.Int).Add(a.Big(), c.Big())},
+				{"-", a.Sub(c), new(big.Int).Sub(a.Big(), c.Big())},
+			} {
+				if op.got.Big().Cmp(op.want) != 0 {
+					t.Errorf("%v %s %v = %v, want %v", a, op.name, c, op.got, op.want)
+				}
+				// A result that fits in an int64 must be held in one, or Cmp
+				// and Int64 on it would disagree with those on NewBigInt.
+				if _, fits := op.got.Int64(); fits != op.want.IsInt64() {
+					t.Errorf("%v %s %v: Int64 reports fits %v, want %v", a, op.name, c, fits, op.want.IsInt64())
+				}
+				if w, ok := op.got.Int64(); ok && op.got.Cmp(NewBigInt(w)) != 0 {
+					t.Errorf("%v %s %v compares unequal to itself as an int64", a, op.name, c)
+				}
+			}
+		}
+	}
+}
+
+func TestParseBigInt(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"0", "0"},
+		{" -42 ", "-42"},
+		{"9223372036854775807", "9223372036854775807"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-9223372036854775809", "-9223372036854775809"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"2^10", "1024"},
+		{"2^63", "9223372036854775808"},
+		{"2^100", "1267650600228229401496703205376"},
+		{"-2^63", "-9223372036854775808"},
+		{"10 ^ 0", "1"},
+	} {
+		got, err := ParseBigInt(c.in)
+		if err != nil {
+			t.Errorf("ParseBigInt(%q): %v", c.in, err)
+			continue
+		}
+		if got.String() != c.want {
+			t.Errorf("ParseBigInt(%q) = %v, want %s", c.in, got, c.want)
+		}
+	}
+	for _, in := range []string{"", "x", "1.5", "2^", "^3", "2^-1", "2^70000", "1e10"} {
+		if _, err := ParseBigInt(in); err == nil {
+			t.Errorf("ParseBigInt(%q) succeeded", in)
+		}
+	}
+}
+
+func TestBigIntDisplay(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"0", "0"},
+		{"999", "999"},
+		{"1000", "1,000"},
+		{"-1234567", "-1,234,567"},
+		{"999999999999999", "999,999,999,999,999"},
+		{"-999999999999999", "-999,999,999,999,999"},
+		{"1000000000000000", "1.0000×10^15"},
+		{"9223372036854775807", "9.2233×10^18"},
+		{"-9223372036854775808", "-9.2233×10^18"},
+		{"2^100", "1.2676×10^30"},
+	} {
+		v, err := ParseBigInt(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.Display(); got != c.want {
+			t.Errorf("Display of %s = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestStepBlocked checks StepBlocked and the unblocked loop against Step on
+// both topologies, with tiles that do not divide the board, tiles larger
+// than it, and margins wider than it.
+func TestStepBlocked(t *testing.T) {
+	cases := []struct{ w, h, n, tile, depth int }{
+		{64, 64, 20, 16, 4},
+		{67, 45, 23, 16, 5},
+		{50, 31, 17, 128, 16},
+		{9, 5, 12, 4, 8},
+		{1, 1, 3, 1, 1},
+		{40, 40, 10, 7, 1},
+	}
+	for _, topo := range []Topology{Torus, Plane} {
+		for _, c := range cases {
+			want := NewStateRand(c.w, c.h, rand.New(rand.NewSource(int64(c.w*c.h))))
+			want.SetTopology(topo)
+			blocked, unblocked := *want, *want
+			blocked.a, blocked.b = want.a.Copy(), want.b.Copy()
+			unblocked.a, unblocked.b = want.a.Copy(), want.b.Copy()
+			for i := 0; i < c.n; i++ {
+				want.Step()
+			}
+			blocked.StepBlocked(c.n, c.tile, c.depth)
+			unblocked.stepUnblocked(c.n)
+			if !blocked.a.Equal(want.a) || blocked.gen != want.gen {
+				t.Errorf("%v %d×%d, tile %d, depth %d: StepBlocked differs from Step", topo, c.w, c.h, c.tile, c.depth)
+			}
+			if !unblocked.a.Equal(want.a) || unblocked.gen != want.gen {
+				t.Errorf("%v %d×%d: unblocked loop differs from Step", topo, c.w, c.h)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CatalogEntry is a well-known pattern.
+type CatalogEntry struct {
+	Name   string
+	Kind   string // "still life", "oscillator", "spaceship", "gun" or "methuselah"
+	Period int    // 1 for still lifes, 0 for patterns that never repeat
+	RLE    string
+}
+
+// catalog lists the patterns built into the program.
+var catalog = []CatalogEntry{
+	{"Block", "still life", 1, "x = 2, y = 2\n2o$2o!"},
+	{"Beehive", "still life", 1, "x = 4, y = 3\nb2o$o2bo$b2o!"},
+	{"Loaf", "still life", 1, "x = 4, y = 4\nb2o$o2bo$bobo$2bo!"},
+	{"Boat", "still life", 1, "x = 3, y = 3\n2o$obo$bo!"},
+	{"Tub", "still life", 1, "x = 3, y = 3\nbo$obo$bo!"},
+	{"Blinker", "oscillator", 2, "x = 3, y = 1\n3o!"},
+	{"Toad", "oscillator", 2, "#O Simon Norton\n#C Discovered: 1970\nx = 4, y = 2\nb3o$3o!"},
+	{"Beacon", "oscillator", 2, "x = 4, y = 4\n2o$2o$2b2o$2b2o!"},
+	{"Pulsar", "oscillator", 3, "x = 13, y = 13\n" +
+		"2b3o3b3o2$o4bobo4bo$o4bobo4bo$o4bobo4bo$2b3o3b3o2$2b3o3b3o$o4bobo4bo$" +
+		"o4bobo4bo$o4bobo4bo2$2b3o3b3o!"},
+	{"Pentadecathlon", "oscillator", 15, "#O John Conway\n#C Discovered: 1970\nx = 10, y = 3\n2bo4bo$2ob4ob2o$2bo4bo!"},
+	{"Glider", "spaceship", 4, "#O Richard K. Guy\n#C Discovered: 1969\nx = 3, y = 3\nbo$2bo$3o!"},
+	{"Lightweight spaceship", "spaceship", 4, "x = 5, y = 4\nbo2bo$o$o3bo$4o!"},
+	{"Gosper glider gun", "gun", 30, "#O Bill Gosper\n#C Discovered: 1970\nx = 36, y = 9\n" +
+		"24bo$22bobo$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o$2o8bo3bob2o4bo" +
+		"bo$10bo5bo7bo$11bo3bo$12b2o!"},
+	{"R-pentomino", "methuselah", 0, "x = 3, y = 3\nb2o$2o$bo!"},
+	{"Diehard", "methuselah", 0, "x = 8, y = 3\n6bo$2o$bo3b3o!"},
+	{"Acorn", "methuselah", 0, "#O Charles Corderman\n#C Discovered: 1971\nx = 7, y = 3\nbo$3bo$2o2b3o!"},
+}
+
+// Pattern parses the entry's pattern. It is named after the entry and links
+// to the entry's LifeWiki article.
+func (e CatalogEntry) Pattern() (*Pattern, error) {
+	p, err := ParseRLE(e.RLE)
+	if err != nil {
+		return nil, fmt.Errorf("catalog entry %s: %v", e.Name, err)
+	}
+	p.Name = e.Name
+	p.URLs = append(p.URLs, "https://conwaylife.com/wiki/"+strings.ReplaceAll(e.Name, " ", "_"))
+	return p, nil
+}
+
+// catalogPattern returns the catalog pattern whose name has the given slug.
+func catalogPattern(id string) (*Pattern, error) {
+	for _, e := range catalog {
+		if slug(e.Name) == id {
+			return e.Pattern()
+		}
+	}
+	return nil, fmt.Errorf("no catalog pattern %q", id)
+}
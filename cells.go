@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+)
+
+// Cells returns the pattern in plaintext .cells format, cropped to the
+// bounding box of its live cells. Metadata is written as "!" comment lines,
+// with name, author, discovery date, rule, generation and position as
+// "Key: value" lines.
+func (p *Pattern) Cells() string {
+	var out strings.Builder
+	for _, c := range append(p.commentLines(true, true), p.placeLines()...) {
+		out.WriteString("!" + c + "\n")
+	}
+	b := p.Board
+	bx, by, w, h := b.Bounds()
+	for y := by; y < by+h; y++ {
+		row := make([]byte, 0, w)
+		for x := bx; x < bx+w; x++ {
+			row = append(row, ".O"[btoi(b.s[y][x])])
+		}
+		out.WriteString(strings.TrimRight(string(row), ".") + "\n")
+	}
+	return out.String()
+}
+
+// ParseCells parses a pattern in plaintext .cells format, on a board just
+// large enough to hold it. Lines starting with "!" are comments; the rest
+// are rows of cells, with 'O' or '*' alive and anything else dead.
+func ParseCells(s string) (*Pattern, error) {
+	p := &Pattern{Rule: Conway}
+	var rows []string
+	w := 0
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "!") {
+			if err := p.parseComment(line[1:], &p.Rule); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rows = append(rows, line)
+		w = max(w, len(line))
+	}
+	for len(rows) > 0 && strings.TrimSpace(rows[len(rows)-1]) == "" {
+		rows = rows[:len(rows)-1]
+	}
+	p.Board = NewBoard(w, len(rows))
+	for y, row := range rows {
+		for x, c := range []byte(row) {
+			if c == 'O' || c == '*' {
+				p.Board.Set(x, y, true)
+			}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+)
+
+// huePalette returns n colors evenly spaced around the hue wheel. Consecutive
+// states get neighboring hues, so the waves and spirals of cyclic models show
+// up as smooth rainbows rather than noise.
+func huePalette(n int) []color.RGBA {
+	p := make([]color.RGBA, n)
+	for i := range p {
+		p[i] = hsv(float64(i)/float64(n), 1, 1)
+	}
+	return p
+}
+
+// hsv converts a hue, saturation and value, each in [0, 1], to a color.
+func hsv(h, s, v float64) color.RGBA {
+	h *= 6
+	i := int(h) % 6
+	f := h - float64(int(h))
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch i {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return color.RGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 255}
+}
+
+// ansiGrid renders g for a 24-bit color terminal, painting each cell with
+// the color its state maps to in pal.
+func ansiGrid(g *Grid, pal []color.RGBA) string {
+	return ansiCells(g.w, g.h, func(x, y int) color.RGBA {
+		return pal[g.Get(x, y)]
+	})
+}
+
+// ansiCells renders a w×h field for a 24-bit color terminal, painting each
+// cell as a space with the background color returned by at.
+func ansiCells(w, h int, at func(x, y int) color.RGBA) string {
+	var buf bytes.Buffer
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := at(x, y)
+			fmt.Fprintf(&buf, "\x1b[48;2;%d;%d;%dm ", c.R, c.G, c.B)
+		}
+		buf.WriteString("\x1b[0m\n")
+	}
+	return buf.String()
+}
+
+// heatStops are the colors colormap interpolates between, from low to high.
+var heatStops = []color.RGBA{
+	{0, 0, 0, 255},
+	{40, 20, 120, 255},
+	{180, 40, 110, 255},
+	{250, 140, 30, 255},
+	{255, 255, 200, 255},
+}
+
+// colormap maps t in [0, 1] to a color on a black–purple–orange–white
+// scale. Values outside the range are clamped.
+func colormap(t float64) color.RGBA {
+	if !(t > 0) {
+		return heatStops[0]
+	}
+	if t >= 1 {
+		return heatStops[len(heatStops)-1]
+	}
+	t *= float64(len(heatStops) - 1)
+	i := int(t)
+	f := t - float64(i)
+	a, b := heatStops[i], heatStops[i+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + f*(float64(y)-float64(x)))
+	}
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math/rand"
+)
+
+// CyclicRule parameterizes Griffeath's cyclic cellular automaton.
+type CyclicRule struct {
+	Colors    int  // number of colors, at most 256
+	Threshold int  // successors needed to advance
+	Range     int  // neighborhood radius
+	Moore     bool // Moore neighborhood if set, von Neumann otherwise
+}
+
+// Cyclic is Griffeath's cyclic cellular automaton. Each cell holds one of
+// Colors colors and advances to the next one, modulo Colors, when at least
+// Threshold cells in its neighborhood already hold that next color.
+type Cyclic struct {
+	a, b *Grid
+	rule CyclicRule
+	pal  []color.RGBA
+}
+
+// NewCyclic returns a cyclic automaton of the given size with every cell
+// colored at random from r.
+func NewCyclic(w, h int, rule CyclicRule, r *rand.Rand) *Cyclic {
+	a := NewGrid(w, h)
+	a.Randomize(rule.Colors, r)
+	return &Cyclic{
+		a: a, b: NewGrid(w, h),
+		rule: rule,
+		pal:  huePalette(rule.Colors),
+	}
+}
+
+// Step advances the automaton by one generation.
+func (c *Cyclic) Step() {
+	for y := 0; y < c.a.h; y++ {
+		for x := 0; x < c.a.w; x++ {
+			v := c.a.Get(x, y)
+			next := uint8((int(v) + 1) % c.rule.Colors)
+			if c.a.Count(x, y, c.rule.Range, c.rule.Moore, next) >= c.rule.Threshold {
+				v = next
+			}
+			c.b.Set(x, y, v)
+		}
+	}
+	c.a, c.b = c.b, c.a
+}
+
+// String returns the field colored by state.
+func (c *Cyclic) String() string {
+	return ansiGrid(c.a, c.pal)
+}
+
+func runCyclic(args []string) error {
+	var (
+		sf   simFlags
+		rule CyclicRule
+	)
+	fs := flag.NewFlagSet("cyclic", flag.ExitOnError)
+	sf.register(fs)
+	fs.IntVar(&rule.Colors, "colors", 14, "number of colors")
+	fs.IntVar(&rule.Threshold, "threshold", 1, "successor neighbors needed to advance")
+	fs.IntVar(&rule.Range, "range", 1, "neighborhood radius")
+	fs.BoolVar(&rule.Moore, "moore", false, "use the Moore neighborhood instead of von Neumann")
+	fs.Parse(args)
+	if err := checkStates("colors", rule.Colors); err != nil {
+		return err
+	}
+	if err := checkNeighborhood(sf.w, sf.h, rule.Range, rule.Moore, rule.Threshold); err != nil {
+		return err
+	}
+	animate(NewCyclic(sf.w, sf.h, rule, sf.rand()), sf.n, sf.delay)
+	return nil
+}
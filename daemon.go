@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// defaultSocket is where the daemon listens unless told otherwise: in the
+// user's runtime directory if there is one, and otherwise in the temporary
+// directory under a name that includes the user ID, so that users sharing
+// a host do not share a daemon.
+var defaultSocket = func() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gameoflife.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gameoflife-%d.sock", os.Getuid()))
+}()
+
+// daemonStepChunk is how many generations of a step request the daemon
+// runs before turning to other requests and viewers again.
+const daemonStepChunk = 4
+
+// daemonRequest is a command sent to the daemon, one JSON object per line.
+// Each is answered with a daemonReply, except "attach", after which the
+// daemon sends a daemonReply with the board every time it changes until
+// the connection is closed.
+type daemonRequest struct {
+	Cmd  string `json:"cmd"`            // "pause", "resume", "step", "save", "status", "stop" or "attach"
+	N    int    `json:"n,omitempty"`    // generations, for "step"; 0 steps one
+	File string `json:"file,omitempty"` // pattern file, for "save"
+}
+
+// daemonStatus describes the daemon's game.
+type daemonStatus struct {
+	Gen      int    `json:"gen"`
+	Pop      int    `json:"population"`
+	Running  bool   `json:"running"`
+	Rule     string `json:"rule"`
+	Topology string `json:"topology"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Viewers  int    `json:"viewers"`
+}
+
+func (s daemonStatus) String() string {
+	state := "paused"
+	if s.Running {
+		state = "running"
+	}
+	return fmt.Sprintf("gen %s  population %d  %s  %s %s %d×%d  %d attached",
+		NewBigInt(int64(s.Gen)).Display(), s.Pop, state, s.Rule, s.Topology, s.Width, s.Height, s.Viewers)
+}
+
+// daemonReply is the daemon's answer to a request, or a frame sent to an
+// attached viewer.
+type daemonReply struct {
+	Error  string       `json:"error,omitempty"`
+	Status daemonStatus `json:"status"`
+	RLE    string       `json:"rle,omitempty"` // the board, for attached viewers
+}
+
+// daemonCall is a request handed from a connection to the goroutine that
+// owns the game. Viewers are identified by the channel their frames are
+// sent on.
+type daemonCall struct {
+	req    daemonRequest
+	reply  chan daemonReply
+	viewer chan daemonReply // for "attach" and "detach"
+}
+
+// daemon owns a game, running it in the background and serving requests
+// from its socket.
+type daemon struct {
+	l       *State
+	running bool
+	delay   time.Duration
+	calls   chan daemonCall
+	stopped chan struct{} // closed once a stop request has been answered
+	viewers map[chan daemonReply]bool
+
+	// Step requests are run a chunk at a time; steps is the number of
+	// generations still to run, and stepReplies the requests to answer
+	// once they are done.
+	steps       int
+	stepReplies []chan daemonReply
+}
+
+func (d *daemon) status() daemonStatus {
+	return daemonStatus{
+		Gen: d.l.gen, Pop: population(d.l.a), Running: d.running,
+		Rule: d.l.rule.String(), Topology: d.l.topo.String(),
+		Width: d.l.w, Height: d.l.h, Viewers: len(d.viewers),
+	}
+}
+
+// frame returns the board for attached viewers.
+func (d *daemon) frame() daemonReply {
+	p := &Pattern{Board: d.l.a, Rule: d.l.rule}
+	p.Gen = NewBigInt(int64(d.l.gen))
+	return daemonReply{Status: d.status(), RLE: p.RLE()}
+}
+
+// broadcast sends the board to every attached viewer. A viewer that has
+// not taken the last frame yet gets this one in its place, so a slow
+// viewer skips frames rather than holding up the game.
+func (d *daemon) broadcast() {
+	f := d.frame()
+	for v := range d.viewers {
+		select {
+		case v <- f:
+		default:
+			select {
+			case <-v:
+			default:
+			}
+			v <- f
+		}
+	}
+}
+
+// do carries out a request, reporting whether the daemon should stop.
+func (d *daemon) do(c daemonCall) (stop bool) {
+	var err error
+	changed := false
+	switch c.req.Cmd {
+	case "status":
+	case "pause":
+		d.running = false
+		changed = true
+	case "resume":
+		d.running = true
+		changed = true
+	case "step":
+		if c.req.N < 0 || c.req.N > math.MaxInt-d.steps {
+			err = fmt.Errorf("cannot step %d generations", c.req.N)
+			break
+		}
+		d.steps += max(c.req.N, 1)
+		d.stepReplies = append(d.stepReplies, c.reply)
+		return false
+	case "save":
+		p := &Pattern{Board: d.l.a, Rule: d.l.rule}
+		p.Gen = NewBigInt(int64(d.l.gen))
+		err = WritePattern(c.req.File, p, defaultLiveBlock, defaultDeadBlock)
+	case "stop":
+		stop = true
+	case "attach":
+		d.viewers[c.viewer] = true
+		changed = true
+	case "detach":
+		delete(d.viewers, c.viewer)
+	default:
+		err = fmt.Errorf("unknown command %q", c.req.Cmd)
+	}
+	if changed {
+		d.broadcast()
+	}
+	if c.reply != nil {
+		r := daemonReply{Status: d.status()}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		c.reply <- r
+	}
+	return stop
+}
+
+// stepChunk runs the next chunk of the generations step requests asked
+// for, answering the requests once all of them are done.
+func (d *daemon) stepChunk() {
+	n := min(d.steps, daemonStepChunk)
+	for i := 0; i < n; i++ {
+		d.l.Step()
+	}
+	d.steps -= n
+	d.broadcast()
+	if d.steps > 0 {
+		return
+	}
+	for _, r := range d.stepReplies {
+		if r != nil {
+			r <- daemonReply{Status: d.status()}
+		}
+	}
+	d.stepReplies = nil
+}
+
+// run serves connections on ln and runs the game until a stop request or
+// a signal arrives.
+func (d *daemon) run(ln net.Listener) {
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, terminateSignal)
+	defer signal.Stop(quit)
+	ticker := time.NewTicker(d.delay)
+	defer ticker.Stop()
+	ready := make(chan struct{})
+	close(ready)
+	for {
+		// While steps are pending, the ready case lets the loop run them
+		// a chunk at a time between the other cases.
+		var stepping <-chan struct{}
+		if d.steps > 0 {
+			stepping = ready
+		}
+		select {
+		case c := <-d.calls:
+			if d.do(c) {
+				<-d.stopped
+				return
+			}
+		case <-stepping:
+			d.stepChunk()
+		case <-ticker.C:
+			if d.running {
+				d.l.Step()
+				d.broadcast()
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// serve answers the requests on a connection.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+	for {
+		var req daemonRequest
+		if err := dec.Decode(&req); err != nil {
+			if err != io.EOF {
+				enc.Encode(daemonReply{Error: err.Error()})
+			}
+			return
+		}
+		if req.Cmd == "attach" {
+			d.attach(conn, enc)
+			return
+		}
+		reply := make(chan daemonReply, 1)
+		d.calls <- daemonCall{req: req, reply: reply}
+		err := enc.Encode(<-reply)
+		if req.Cmd == "stop" {
+			// The daemon waits for the answer to be sent before exiting.
+			close(d.stopped)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// attach sends frames to a viewer until it hangs up.
+func (d *daemon) attach(conn net.Conn, enc *json.Encoder) {
+	v := make(chan daemonReply, 1)
+	d.calls <- daemonCall{req: daemonRequest{Cmd: "attach"}, viewer: v}
+	defer func() {
+		d.calls <- daemonCall{req: daemonRequest{Cmd: "detach"}, viewer: v}
+	}()
+	gone := make(chan struct{})
+	go func() {
+		// Viewers send nothing more, so a read returns only once they
+		// detach.
+		io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+	for {
+		select {
+		case f := <-v:
+			if enc.Encode(f) != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// listenUnix listens on the Unix socket at path, replacing a socket left
+// behind by a daemon that is no longer running.
+func listenUnix(path string) (net.Listener, error) {
+	ln, err := net.Listen("unix", path)
+	if err == nil {
+		return ln, nil
+	}
+	if c, derr := net.Dial("unix", path); derr == nil {
+		c.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if fi, serr := os.Lstat(path); serr != nil || fi.Mode()&os.ModeSocket == 0 || os.Remove(path) != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// daemonCommand sends a request to the daemon listening at socket and
+// returns its reply.
+func daemonCommand(socket string, req daemonRequest) (daemonReply, error) {
+	var r daemonReply
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return r, fmt.Errorf("no daemon at %s: %v", socket, err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return r, err
+	}
+	if err := json.NewDecoder(conn).Decode(&r); err != nil {
+		return r, fmt.Errorf("daemon at %s: %v", socket, err)
+	}
+	if r.Error != "" {
+		return r, errors.New(r.Error)
+	}
+	return r, nil
+}
+
+func runDaemon(args []string) error {
+	var (
+		sf             simFlags
+		socket         string
+		file, name     string
+		paused, detach bool
+	)
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&socket, "socket", defaultSocket, "Unix socket to listen on")
+	fs.StringVar(&file, "file", "", "start from the pattern in this .rle, .cells, .mc or .schem file")
+	fs.StringVar(&name, "pattern", "", "start from this catalog pattern")
+	fs.BoolVar(&paused, "paused", false, "start paused")
+	fs.BoolVar(&detach, "detach", false, "run in the background, detached from the terminal")
+	fs.Parse(args)
+	// The delay is the period of the game's clock, which must tick.
+	if sf.delay <= 0 {
+		return errors.New("delay must be positive")
+	}
+
+	if detach {
+		// Start again without -detach in a session of its own, and wait
+		// until it answers on its socket.
+		var rest []string
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "detach" {
+				rest = append(rest, "-"+f.Name+"="+f.Value.String())
+			}
+		})
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(exe, append([]string{"daemon"}, rest...)...)
+		detachProcess(cmd)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		for i := 0; i < 50; i++ {
+			if _, err := daemonCommand(socket, daemonRequest{Cmd: "status"}); err == nil {
+				fmt.Printf("daemon %d listening on %s\n", cmd.Process.Pid, socket)
+				return cmd.Process.Release()
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return fmt.Errorf("daemon %d did not start listening on %s", cmd.Process.Pid, socket)
+	}
+
+	l := NewStateRand(sf.w, sf.h, sf.rand())
+	var p *Pattern
+	var err error
+	switch {
+	case file != "":
+		p, err = ReadPattern(file, defaultLiveBlock)
+	case name != "":
+		p, err = catalogPattern(name)
+	}
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		// The pattern goes in the middle of an otherwise empty board.
+		l.a = NewBoard(sf.w, sf.h)
+		px, py := (sf.w-p.Board.w)/2, (sf.h-p.Board.h)/2
+		for y := 0; y < p.Board.h; y++ {
+			for x := 0; x < p.Board.w; x++ {
+				if p.Board.Active(x, y) {
+					l.a.Set(((px+x)%sf.w+sf.w)%sf.w, ((py+y)%sf.h+sf.h)%sf.h, true)
+				}
+			}
+		}
+		l.SetRule(p.Rule)
+	}
+
+	ln, err := listenUnix(socket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Println("listening on", socket)
+	d := &daemon{
+		l: l, running: !paused, delay: sf.delay,
+		calls: make(chan daemonCall), stopped: make(chan struct{}), viewers: map[chan daemonReply]bool{},
+	}
+	d.run(ln)
+	return nil
+}
+
+func runControl(args []string) error {
+	var socket string
+	fs := flag.NewFlagSet("control", flag.ExitOnError)
+	fs.StringVar(&socket, "socket", defaultSocket, "Unix socket the daemon listens on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gameoflife control [-socket path] pause|resume|step [n]|save file|status|stop")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("control needs a command")
+	}
+	req := daemonRequest{Cmd: fs.Arg(0)}
+	switch req.Cmd {
+	case "step":
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+			if err != nil || n < 1 {
+				return fmt.Errorf("malformed number of generations %q", fs.Arg(1))
+			}
+			req.N = n
+		}
+	case "save":
+		if fs.NArg() < 2 {
+			return errors.New("save needs a pattern file")
+		}
+		// The daemon may run in another directory.
+		f, err := filepath.Abs(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		req.File = f
+	case "attach":
+		return errors.New("use the attach command to watch the daemon")
+	}
+	r, err := daemonCommand(socket, req)
+	if err != nil {
+		return err
+	}
+	switch req.Cmd {
+	case "save":
+		fmt.Println("saved", req.File)
+	case "stop":
+		fmt.Println("stopped")
+	default:
+		fmt.Println(r.Status)
+	}
+	return nil
+}
+
+// attachHelp summarizes the attach command's key bindings.
+const attachHelp = "q:detach spc:pause/resume n:step arrows:scroll"
+
+// viewer is the state of an attached viewer: the latest frame from the
+// daemon and the part of the board shown.
+type viewer struct {
+	status daemonStatus
+	b      *Board
+	ox, oy int // board cell shown at the top left of the screen
+	w, h   int // terminal size
+	msg    string
+}
+
+// load replaces the board with the one in frame f.
+func (v *viewer) load(f daemonReply) error {
+	p, err := ParseRLE(f.RLE)
+	if err != nil {
+		return err
+	}
+	v.status = f.Status
+	v.b = NewBoard(f.Status.Width, f.Status.Height)
+	x0, _ := p.X.Int64()
+	y0, _ := p.Y.Int64()
+	for y := 0; y < p.Board.h; y++ {
+		for x := 0; x < p.Board.w; x++ {
+			if p.Board.s[y][x] {
+				v.b.Set(int(x0)+x, int(y0)+y, true)
+			}
+		}
+	}
+	return nil
+}
+
+// draw returns the escape sequences that repaint the whole screen.
+func (v *viewer) draw() string {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	vw, vh := v.w, max(v.h-2, 1)
+	if v.b != nil {
+		v.ox = max(0, min(v.ox, v.b.w-vw))
+		v.oy = max(0, min(v.oy, v.b.h-vh))
+		for y := v.oy; y < v.oy+vh && y < v.b.h; y++ {
+			for x := v.ox; x < v.ox+vw && x < v.b.w; x++ {
+				buf.WriteByte(" *"[btoi(v.b.s[y][x])])
+			}
+			buf.WriteString("\x1b[K\n")
+		}
+	}
+	buf.WriteString("\x1b[J")
+	status := v.status.String() + "  " + v.msg
+	buf.WriteString(clipRunes(status, v.w) + "\x1b[K\n")
+	buf.WriteString(clipRunes(attachHelp, v.w) + "\x1b[K")
+	return buf.String()
+}
+
+func runAttach(args []string) error {
+	var socket string
+	fs := flag.NewFlagSet("attach", flag.ExitOnError)
+	fs.StringVar(&socket, "socket", defaultSocket, "Unix socket the daemon listens on")
+	fs.Parse(args)
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("no daemon at %s: %v", socket, err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Cmd: "attach"}); err != nil {
+		return err
+	}
+	frames := make(chan daemonReply)
+	go func() {
+		dec := json.NewDecoder(conn)
+		for {
+			var f daemonReply
+			if err := dec.Decode(&f); err != nil {
+				close(frames)
+				return
+			}
+			frames <- f
+		}
+	}()
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	v := &viewer{}
+	if v.w, v.h, err = terminalSize(); err != nil || v.w == 0 || v.h == 0 {
+		v.w, v.h = 80, 24
+	}
+	input := make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(input)
+				return
+			}
+			input <- buf[:n]
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	// Commands go over connections of their own, while this one carries
+	// only frames.
+	control := func(req daemonRequest) {
+		v.msg = ""
+		if _, err := daemonCommand(socket, req); err != nil {
+			v.msg = err.Error()
+		}
+	}
+	for {
+		os.Stdout.WriteString(v.draw())
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				return errors.New("the daemon went away")
+			}
+			if err := v.load(f); err != nil {
+				return err
+			}
+		case b, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for _, ev := range parseInput(b) {
+				switch ev.Key {
+				case "q", "ctrl-c":
+					return nil
+				case " ":
+					if v.status.Running {
+						control(daemonRequest{Cmd: "pause"})
+					} else {
+						control(daemonRequest{Cmd: "resume"})
+					}
+				case "n", ".":
+					control(daemonRequest{Cmd: "step"})
+				case "left", "h":
+					v.ox -= max(v.w/4, 1)
+				case "right", "l":
+					v.ox += max(v.w/4, 1)
+				case "up", "k":
+					v.oy -= max(v.h/4, 1)
+				case "down", "j":
+					v.oy += max(v.h/4, 1)
+				}
+			}
+		case <-resize:
+			if w, h, err := terminalSize(); err == nil {
+				v.w, v.h = w, h
+			}
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dashPanels are the panels of the terminal UI's analysis dashboard, each
+// shown or hidden with its key.
+var dashPanels = [...]struct {
+	key, title string
+}{
+	{"C", "census"},
+	{"O", "oscillators and spaceships"},
+	{"G", "population"},
+	{"L", "events"},
+}
+
+// sidePanelWidth is the width of the dashboard when it fits beside the
+// board; on terminals narrower than minSideWidth it goes below instead.
+const (
+	sidePanelWidth = 36
+	minSideWidth   = 100
+)
+
+// maxObjectPeriod is the longest period looked for in an object.
+const maxObjectPeriod = 30
+
+// maxEvents is the number of events each tab's log keeps.
+const maxEvents = 100
+
+// sample is what a tab's analysis records about one generation.
+type sample struct {
+	gen, pop int
+	hash     uint64
+}
+
+// analysis follows a tab's game for the dashboard: its population over
+// time, the period it has settled into, what happened, and the objects on
+// the board.
+type analysis struct {
+	observed bool
+	samples  []sample // one per observed generation, oldest first
+	since    int      // generation of the oldest sample the board has evolved from unedited
+	period   int      // period the board has settled into, or 0
+	events   []string
+
+	objs   []object         // objects on the board, or nil until found
+	rule   Rule             // rule the shapes were classified under
+	shapes map[string]shape // classified shapes by shapeKey
+}
+
+// observe records the board of l if it has changed since it was last
+// observed, logging extinction and stabilization.
+func (a *analysis) observe(l *State) {
+	h := l.a.Hash()
+	var last sample
+	if a.observed {
+		last = a.samples[len(a.samples)-1]
+		if last.gen == l.gen && last.hash == h {
+			return
+		}
+		if l.gen <= last.gen {
+			// The board was edited or stepped back, so its history no
+			// longer leads to it.
+			i := sort.Search(len(a.samples), func(i int) bool { return a.samples[i].gen >= l.gen })
+			a.samples = a.samples[:i]
+			a.since, a.period = l.gen, 0
+		}
+	}
+	a.objs = nil
+	s := sample{gen: l.gen, pop: population(l.a), hash: h}
+	period := 0
+	for i := len(a.samples) - 1; i >= 0 && a.samples[i].gen >= a.since; i-- {
+		if a.samples[i].hash == h {
+			period = l.gen - a.samples[i].gen
+			break
+		}
+	}
+	switch {
+	case s.pop == 0 && a.observed && last.pop > 0 && l.gen > last.gen:
+		a.log(l.gen, "died out")
+	case period > 0 && period != a.period && s.pop > 0:
+		if period == 1 {
+			a.log(l.gen, "became still")
+		} else {
+			a.log(l.gen, fmt.Sprintf("settled into period %d", period))
+		}
+	}
+	a.period = period
+	if len(a.samples) == maxHistory {
+		a.samples = append(a.samples[:0], a.samples[1:]...)
+	}
+	a.samples = append(a.samples, s)
+	a.observed = true
+}
+
+func (a *analysis) log(gen int, event string) {
+	if len(a.events) == maxEvents {
+		a.events = append(a.events[:0], a.events[1:]...)
+	}
+	a.events = append(a.events, fmt.Sprintf("gen %s: %s", NewBigInt(int64(gen)).Display(), event))
+}
+
+// shape is what an object's cells do when left alone.
+type shape struct {
+	period int    // 0 if they do not repeat within maxObjectPeriod
+	dx, dy int    // how far they move each period
+	name   string // catalog name, or ""
+}
+
+// object is a group of live cells on the board and what it does.
+type object struct {
+	x, y int // top left of its bounding box
+	pop  int
+	shape
+}
+
+// kind returns "still life", "oscillator", "spaceship" or "other".
+func (s shape) kind() string {
+	switch {
+	case s.period == 0:
+		return "other"
+	case s.dx != 0 || s.dy != 0:
+		return "spaceship"
+	case s.period == 1:
+		return "still life"
+	}
+	return "oscillator"
+}
+
+// label names the shape for the census: by name if it is in the catalog,
+// and otherwise by its kind and period.
+func (s shape) label() string {
+	switch {
+	case s.name != "":
+		return strings.ToLower(s.name)
+	case s.period > 1:
+		return fmt.Sprintf("p%d %s", s.period, s.kind())
+	}
+	return s.kind()
+}
+
+// velocity returns the shape's speed and direction, such as c/4 SE.
+func (s shape) velocity() string {
+	d := max(abs(s.dx), abs(s.dy))
+	if d == 0 {
+		return ""
+	}
+	speed := fmt.Sprintf("%dc/%d", d, s.period)
+	if d == 1 {
+		speed = fmt.Sprintf("c/%d", s.period)
+	}
+	dir := ""
+	switch {
+	case s.dy < 0:
+		dir = "N"
+	case s.dy > 0:
+		dir = "S"
+	}
+	switch {
+	case s.dx < 0:
+		dir += "W"
+	case s.dx > 0:
+		dir += "E"
+	}
+	return speed + " " + dir
+}
+
+// objects returns the objects on l's board, classifying their shapes under
+// its rule.
+func (a *analysis) objects(l *State) []object {
+	if a.objs != nil {
+		return a.objs
+	}
+	if a.shapes == nil || a.rule != l.rule {
+		a.shapes, a.rule = map[string]shape{}, l.rule
+	}
+	a.objs = []object{}
+	for _, is := range islands(l.a) {
+		k := shapeKey(is.b)
+		s, ok := a.shapes[k]
+		if !ok {
+			s = classify(is.b, l.rule)
+			a.shapes[k] = s
+		}
+		a.objs = append(a.objs, object{x: is.x, y: is.y, pop: population(is.b), shape: s})
+	}
+	return a.objs
+}
+
+// island is a group of live cells cropped to its bounding box, whose top
+// left is at x, y on the board it came from.
+type island struct {
+	x, y int
+	b    *Board
+}
+
+// islands splits the live cells of b into groups in which each cell is
+// within two cells of another, which keeps together objects such as the
+// toad whose cells do not all touch. Cells are grouped as on a plane, so
+// an object across a wrapped edge counts as two.
+func islands(b *Board) []island {
+	seen := NewBoard(b.w, b.h)
+	var out []island
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if !b.s[y][x] || seen.s[y][x] {
+				continue
+			}
+			cells := [][2]int{{x, y}}
+			seen.Set(x, y, true)
+			for i := 0; i < len(cells); i++ {
+				cx, cy := cells[i][0], cells[i][1]
+				for ny := max(cy-2, 0); ny <= min(cy+2, b.h-1); ny++ {
+					for nx := max(cx-2, 0); nx <= min(cx+2, b.w-1); nx++ {
+						if b.s[ny][nx] && !seen.s[ny][nx] {
+							seen.Set(nx, ny, true)
+							cells = append(cells, [2]int{nx, ny})
+						}
+					}
+				}
+			}
+			x0, y0, x1, y1 := x, y, x, y
+			for _, c := range cells {
+				x0, y0 = min(x0, c[0]), min(y0, c[1])
+				x1, y1 = max(x1, c[0]), max(y1, c[1])
+			}
+			is := island{x: x0, y: y0, b: NewBoard(x1-x0+1, y1-y0+1)}
+			for _, c := range cells {
+				is.b.Set(c[0]-x0, c[1]-y0, true)
+			}
+			out = append(out, is)
+		}
+	}
+	return out
+}
+
+// crop returns the w×h part of b with its top left at x, y.
+func crop(b *Board, x, y, w, h int) *Board {
+	c := NewBoard(w, h)
+	for i := 0; i < h; i++ {
+		copy(c.s[i], b.s[y+i][x:x+w])
+	}
+	return c
+}
+
+// shapeKey returns a string identifying the cells of b.
+func shapeKey(b *Board) string {
+	var s strings.Builder
+	for _, row := range b.s {
+		for _, a := range row {
+			s.WriteByte(".O"[btoi(a)])
+		}
+		s.WriteByte('$')
+	}
+	return s.String()
+}
+
+// classify runs the cells of b, which fill its bounding box, alone on a
+// plane under r until they come back to their shape, and returns what they
+// did.
+func classify(b *Board, r Rule) shape {
+	m := maxObjectPeriod + 2
+	l := &State{a: padded(b, m), w: b.w + 2*m, h: b.h + 2*m, rule: r, topo: Plane}
+	l.b = NewBoard(l.w, l.h)
+	for g := 1; g <= maxObjectPeriod; g++ {
+		l.Step()
+		x, y, w, h := l.a.Bounds()
+		if w == 0 {
+			break
+		}
+		if w == b.w && h == b.h && crop(l.a, x, y, w, h).Equal(b) {
+			s := shape{period: g, dx: x - m, dy: y - m}
+			if r == Conway {
+				s.name = catalogShapes()[shapeKey(b)]
+			}
+			return s
+		}
+	}
+	return shape{}
+}
+
+// catalogShapeNames maps the shapeKey of every phase and orientation of
+// the catalog's still lifes, oscillators and spaceships to their names.
+var catalogShapeNames map[string]string
+
+func catalogShapes() map[string]string {
+	if catalogShapeNames != nil {
+		return catalogShapeNames
+	}
+	catalogShapeNames = map[string]string{}
+	for _, e := range catalog {
+		if e.Kind != "still life" && e.Kind != "oscillator" && e.Kind != "spaceship" {
+			continue
+		}
+		p, err := e.Pattern()
+		if err != nil {
+			continue
+		}
+		m := e.Period + 2
+		l := &State{a: padded(p.Board, m), w: p.Board.w + 2*m, h: p.Board.h + 2*m, rule: p.Rule, topo: Plane}
+		l.b = NewBoard(l.w, l.h)
+		for g := 0; g < e.Period; g++ {
+			x, y, w, h := l.a.Bounds()
+			b := crop(l.a, x, y, w, h)
+			for i := 0; i < 8; i++ {
+				catalogShapeNames[shapeKey(b)] = e.Name
+				// Alternate transposing and flipping to visit all eight
+				// orientations.
+				if i%2 == 0 {
+					b = transpose(b)
+				} else {
+					b = flip(b)
+				}
+			}
+			l.Step()
+		}
+	}
+	return catalogShapeNames
+}
+
+// transpose returns b mirrored along its main diagonal.
+func transpose(b *Board) *Board {
+	t := NewBoard(b.h, b.w)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			t.s[x][y] = b.s[y][x]
+		}
+	}
+	return t
+}
+
+// flip returns b mirrored left to right.
+func flip(b *Board) *Board {
+	f := NewBoard(b.w, b.h)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			f.s[y][b.w-1-x] = b.s[y][x]
+		}
+	}
+	return f
+}
+
+// togglePanel shows or hides the dashboard panel with the given key,
+// reporting whether there is one.
+func (u *ui) togglePanel(key string) bool {
+	for i, p := range dashPanels {
+		if p.key == key {
+			u.panels[i] = !u.panels[i]
+			return true
+		}
+	}
+	return false
+}
+
+// dashboard returns the lines of the open panels for the current tab,
+// stacked in a w×h area: each panel gets an equal share of the rows.
+func (u *ui) dashboard(w, h int) []string {
+	var open []int
+	for i, on := range u.panels {
+		if on {
+			open = append(open, i)
+		}
+	}
+	var lines []string
+	for n, i := range open {
+		rows := h/len(open) + btoi(n < h%len(open))
+		if rows == 0 {
+			continue
+		}
+		title := fmt.Sprintf("─ %s (%s) ", dashPanels[i].title, dashPanels[i].key)
+		lines = append(lines, clipRunes(title+strings.Repeat("─", w), w))
+		body := u.panel(dashPanels[i].key, w, rows-1)
+		for r := 0; r < rows-1; r++ {
+			line := ""
+			if r < len(body) {
+				line = clipRunes(body[r], w)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// panel returns at most h lines of the panel with the given key for the
+// current tab.
+func (u *ui) panel(key string, w, h int) []string {
+	if h == 0 {
+		return nil
+	}
+	t := u.tab()
+	a := &t.dash
+	var lines []string
+	switch key {
+	case "C":
+		objs := a.objects(t.l)
+		count := map[string]int{}
+		for _, o := range objs {
+			count[o.label()]++
+		}
+		labels := make([]string, 0, len(count))
+		for l := range count {
+			labels = append(labels, l)
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			if count[labels[i]] != count[labels[j]] {
+				return count[labels[i]] > count[labels[j]]
+			}
+			return labels[i] < labels[j]
+		})
+		lines = append(lines, fmt.Sprintf("%d objects", len(objs)))
+		for _, l := range labels {
+			lines = append(lines, fmt.Sprintf("%5d %s", count[l], l))
+		}
+	case "O":
+		for _, o := range a.objects(t.l) {
+			if o.period > 1 || o.kind() == "spaceship" {
+				lines = append(lines, fmt.Sprintf("%-14s p%-2d %3d,%-3d %s",
+					clipRunes(o.label(), 14), o.period, o.x, o.y, o.velocity()))
+			}
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "none")
+		}
+	case "G":
+		lines = populationGraph(a.samples, w, h)
+	case "L":
+		lines = a.events
+		if len(lines) == 0 {
+			lines = []string{"nothing yet"}
+		}
+	}
+	if len(lines) > h {
+		if key == "L" {
+			// The log shows its latest events.
+			return lines[len(lines)-h:]
+		}
+		more := fmt.Sprintf("... %d more", len(lines)-h+1)
+		lines = append(lines[:h-1:h-1], more)
+	}
+	return lines
+}
+
+// graphBars are the characters drawing a bar an eighth of a row high to a
+// full row.
+var graphBars = []rune(" ▁▂▃▄▅▆▇█")
+
+// populationGraph draws the population of the latest samples as a bar
+// chart w columns wide and h rows high, the first row giving the scale.
+// The bars run from the lowest population shown to the highest, so that
+// small changes in a large population show.
+func populationGraph(samples []sample, w, h int) []string {
+	if h == 0 || len(samples) == 0 {
+		return nil
+	}
+	samples = samples[max(0, len(samples)-w):]
+	lo, hi := samples[0].pop, samples[0].pop
+	for _, s := range samples {
+		lo, hi = min(lo, s.pop), max(hi, s.pop)
+	}
+	last := samples[len(samples)-1]
+	lines := []string{fmt.Sprintf("now %d  range %d-%d  gens %d-%d", last.pop, lo, hi, samples[0].gen, last.gen)}
+	rows := h - 1
+	for r := rows - 1; r >= 0; r-- {
+		line := make([]rune, len(samples))
+		for i, s := range samples {
+			// The height of the bar in eighths of a row, at least one so
+			// that every generation shows.
+			e := rows * 8
+			if hi > lo {
+				e = max(1, (s.pop-lo)*rows*8/(hi-lo))
+			}
+			line[i] = graphBars[min(max(e-r*8, 0), 8)]
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// clipRunes truncates s to w characters.
+func clipRunes(s string, w int) string {
+	if r := []rune(s); len(r) > w {
+		return string(r[:w])
+	}
+	return s
+}
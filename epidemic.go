@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math/rand"
+)
+
+// States of an Epidemic cell.
+const (
+	susceptible = iota
+	infected
+	recovered
+)
+
+// EpidemicRule parameterizes the lattice SIR model.
+type EpidemicRule struct {
+	Infection float64 // probability of transmission from each infected neighbor
+	Recovery  float64 // probability that an infected cell recovers
+	Moore     bool    // infect diagonal neighbors as well as orthogonal ones
+}
+
+// Epidemic is a stochastic SIR epidemic on the grid. Each generation an
+// infected cell recovers with probability Recovery, and a susceptible cell
+// is infected independently by each of its infected neighbors with
+// probability Infection. Recovered cells are immune.
+type Epidemic struct {
+	a, b *Grid
+	rule EpidemicRule
+	r    *rand.Rand
+}
+
+// NewEpidemic returns a susceptible population of the given size with n
+// cells, chosen at random, infected.
+func NewEpidemic(w, h int, rule EpidemicRule, n int, r *rand.Rand) *Epidemic {
+	a := NewGrid(w, h)
+	for i := 0; i < n; i++ {
+		a.Set(r.Intn(w), r.Intn(h), infected)
+	}
+	return &Epidemic{a: a, b: NewGrid(w, h), rule: rule, r: r}
+}
+
+// Step advances the epidemic by one generation.
+func (e *Epidemic) Step() {
+	for y := 0; y < e.a.h; y++ {
+		for x := 0; x < e.a.w; x++ {
+			v := e.a.Get(x, y)
+			switch v {
+			case infected:
+				if e.r.Float64() < e.rule.Recovery {
+					v = recovered
+				}
+			case susceptible:
+				for k := e.a.Count(x, y, 1, e.rule.Moore, infected); k > 0; k-- {
+					if e.r.Float64() < e.rule.Infection {
+						v = infected
+						break
+					}
+				}
+			}
+			e.b.Set(x, y, v)
+		}
+	}
+	e.a, e.b = e.b, e.a
+}
+
+// Census returns the number of susceptible, infected and recovered cells.
+func (e *Epidemic) Census() []int {
+	return e.a.Census(3)
+}
+
+// Clusters returns the sizes of the connected clusters of recovered cells,
+// the footprints of the outbreaks.
+func (e *Epidemic) Clusters() []int {
+	return e.a.Clusters(recovered, e.rule.Moore)
+}
+
+var epidemicPalette = []color.RGBA{
+	susceptible: {60, 60, 90, 255},
+	infected:    {220, 30, 30, 255},
+	recovered:   {200, 200, 200, 255},
+}
+
+// String returns the population colored by compartment.
+func (e *Epidemic) String() string {
+	return ansiGrid(e.a, epidemicPalette)
+}
+
+func runEpidemic(args []string) error {
+	var (
+		sf    simFlags
+		rule  EpidemicRule
+		n     int
+		out   string
+		quiet bool
+	)
+	fs := flag.NewFlagSet("sir", flag.ExitOnError)
+	sf.register(fs)
+	fs.Float64Var(&rule.Infection, "beta", 0.3, "probability of transmission per infected neighbor")
+	fs.Float64Var(&rule.Recovery, "gamma", 0.1, "probability of recovery per generation")
+	fs.BoolVar(&rule.Moore, "moore", true, "infect diagonal neighbors too")
+	fs.IntVar(&n, "infected", 5, "number of initially infected cells")
+	fs.StringVar(&out, "csv", "", "write per-generation counts to this CSV file")
+	fs.BoolVar(&quiet, "quiet", false, "do not draw the population")
+	fs.Parse(args)
+	e := NewEpidemic(sf.w, sf.h, rule, n, sf.rand())
+	return runCompartments(e, []string{"susceptible", "infected", "recovered"}, sf, out, quiet)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// EventKind distinguishes the kinds of Event.
+type EventKind int
+
+const (
+	KeyEvent EventKind = iota
+	MouseEvent
+	ResizeEvent
+)
+
+// Event is a single input to the terminal UI.
+type Event struct {
+	Kind EventKind
+	Key  string // name of the key, for KeyEvent
+	X, Y int    // screen cell clicked, for MouseEvent; new size, for ResizeEvent
+}
+
+// parseInput splits raw terminal input into events. Printable keys are named
+// by the character they type; others are given names such as "up", "enter"
+// or "ctrl-c". Only mouse button presses reported in SGR mode are decoded.
+func parseInput(b []byte) []Event {
+	var evs []Event
+	key := func(k string) {
+		evs = append(evs, Event{Kind: KeyEvent, Key: k})
+	}
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) >= 3 && b[1] == '[' && b[2] == '<':
+			// SGR mouse report: ESC [ < button ; x ; y M (press) or m (release).
+			end := strings.IndexAny(string(b), "Mm")
+			if end < 0 {
+				return evs
+			}
+			f := strings.Split(string(b[3:end]), ";")
+			if len(f) == 3 && b[end] == 'M' && f[0] == "0" {
+				x, errx := strconv.Atoi(f[1])
+				y, erry := strconv.Atoi(f[2])
+				if errx == nil && erry == nil && x >= 1 && y >= 1 {
+					evs = append(evs, Event{Kind: MouseEvent, X: x - 1, Y: y - 1})
+				}
+			}
+			b = b[end+1:]
+		case c == 0x1b && len(b) >= 2 && b[1] == '[':
+			// A CSI sequence runs through parameter and intermediate bytes
+			// to a final byte in 0x40–0x7e. Sequences other than the arrow
+			// keys, such as ESC [ 3 ~ for delete, are consumed and ignored.
+			end := 2
+			for end < len(b) && b[end] >= 0x20 && b[end] < 0x40 {
+				end++
+			}
+			if end == len(b) {
+				return evs
+			}
+			if b[end] < 0x20 || b[end] > 0x7e {
+				key("esc")
+				b = b[1:]
+				break
+			}
+			if end == 2 {
+				switch b[2] {
+				case 'A':
+					key("up")
+				case 'B':
+					key("down")
+				case 'C':
+					key("right")
+				case 'D':
+					key("left")
+				}
+			}
+			b = b[end+1:]
+		case c == 0x1b:
+			key("esc")
+			b = b[1:]
+		case c == '\r' || c == '\n':
+			key("enter")
+			b = b[1:]
+		case c == '\t':
+			key("tab")
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			key("backspace")
+			b = b[1:]
+		case c < 0x20:
+			key("ctrl-" + string(rune('a'+c-1)))
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			key(string(r))
+			b = b[n:]
+		}
+	}
+	return evs
+}
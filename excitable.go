@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"math/rand"
+)
+
+// ExcitableRule parameterizes the Greenberg–Hastings model.
+type ExcitableRule struct {
+	States    int  // resting, excited and States-2 refractory states
+	Threshold int  // excited neighbors needed to excite a resting cell
+	Range     int  // neighborhood radius
+	Moore     bool // Moore neighborhood if set, von Neumann otherwise
+}
+
+// Excitable is the Greenberg–Hastings model of an excitable medium.
+// State 0 is resting, 1 is excited and the rest are refractory. A resting
+// cell becomes excited when at least Threshold cells in its neighborhood are
+// excited; every other state advances to the next, wrapping back to resting.
+type Excitable struct {
+	a, b *Grid
+	rule ExcitableRule
+	pal  []color.RGBA
+}
+
+// NewExcitable returns a Greenberg–Hastings medium of the given size with
+// every cell in a state chosen at random from r.
+func NewExcitable(w, h int, rule ExcitableRule, r *rand.Rand) *Excitable {
+	a := NewGrid(w, h)
+	a.Randomize(rule.States, r)
+	// Resting cells are black, excited cells white, and refractory cells
+	// fade from red back towards black.
+	pal := make([]color.RGBA, rule.States)
+	pal[0] = color.RGBA{0, 0, 0, 255}
+	pal[1] = color.RGBA{255, 255, 255, 255}
+	for i := 2; i < rule.States; i++ {
+		pal[i] = hsv(0, 1, 1-float64(i-1)/float64(rule.States))
+	}
+	return &Excitable{
+		a: a, b: NewGrid(w, h),
+		rule: rule,
+		pal:  pal,
+	}
+}
+
+// Step advances the medium by one generation.
+func (e *Excitable) Step() {
+	for y := 0; y < e.a.h; y++ {
+		for x := 0; x < e.a.w; x++ {
+			v := e.a.Get(x, y)
+			switch {
+			case v != 0:
+				v = uint8((int(v) + 1) % e.rule.States)
+			case e.a.Count(x, y, e.rule.Range, e.rule.Moore, 1) >= e.rule.Threshold:
+				v = 1
+			}
+			e.b.Set(x, y, v)
+		}
+	}
+	e.a, e.b = e.b, e.a
+}
+
+// String returns the field colored by state.
+func (e *Excitable) String() string {
+	return ansiGrid(e.a, e.pal)
+}
+
+func runExcitable(args []string) error {
+	var (
+		sf   simFlags
+		rule ExcitableRule
+	)
+	fs := flag.NewFlagSet("gh", flag.ExitOnError)
+	sf.register(fs)
+	fs.IntVar(&rule.States, "states", 8, "number of states")
+	fs.IntVar(&rule.Threshold, "threshold", 1, "excited neighbors needed to fire")
+	fs.IntVar(&rule.Range, "range", 1, "neighborhood radius")
+	fs.BoolVar(&rule.Moore, "moore", true, "use the Moore neighborhood instead of von Neumann")
+	fs.Parse(args)
+	if err := checkStates("states", rule.States); err != nil {
+		return err
+	}
+	if err := checkNeighborhood(sf.w, sf.h, rule.Range, rule.Moore, rule.Threshold); err != nil {
+		return err
+	}
+	if rule.States < 3 {
+		return fmt.Errorf("states must be at least 3, got %d", rule.States)
+	}
+	animate(NewExcitable(sf.w, sf.h, rule, sf.rand()), sf.n, sf.delay)
+	return nil
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manifest describes a parameter sweep: one run for every combination of
+// the values listed. For example:
+//
+//	{
+//		"rules": ["B3/S23", "B36/S23"],
+//		"sizes": ["64x64", "256x256"],
+//		"densities": [0.2, 0.35],
+//		"seeds": [1, 2, 3],
+//		"generations": [1000],
+//		"engines": ["step", "bitsliced"]
+//	}
+type Manifest struct {
+	Rules       []string  `json:"rules"`
+	Sizes       []string  `json:"sizes"`       // board sizes, such as "64x64"
+	Densities   []float64 `json:"densities"`   // chance of each cell starting alive; 0.25 if none
+	Seeds       []int64   `json:"seeds"`       // random seeds; 1 if none
+	Generations []int     `json:"generations"` // generations to run
+	Engines     []string  `json:"engines"`     // "step", "blocked", "bitsliced" or "hashlife"; "step" if none
+	Topology    string    `json:"topology"`    // "torus", the default, or "plane"
+}
+
+// engines are the ways a run can be stepped.
+var engines = map[string]bool{"step": true, "blocked": true, "bitsliced": true, "hashlife": true}
+
+// RunParams are the parameters of one run of a sweep.
+type RunParams struct {
+	Rule        string  `json:"rule"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Density     float64 `json:"density"`
+	Seed        int64   `json:"seed"`
+	Generations int     `json:"generations"`
+	Engine      string  `json:"engine"`
+	Topology    string  `json:"topology"` // "unbounded plane" for hashlife
+}
+
+// ID names the run's directory in the output.
+func (p RunParams) ID() string {
+	return fmt.Sprintf("%s_%dx%d_d%g_s%d_g%d_%s_%s", strings.ReplaceAll(p.Rule, "/", "-"),
+		p.Width, p.Height, p.Density, p.Seed, p.Generations, p.Engine, strings.ReplaceAll(p.Topology, " ", "-"))
+}
+
+// RunResult is what a run found and how long it took.
+type RunResult struct {
+	Population int64    `json:"population"`
+	Bounds     [4]int64 `json:"bounds"` // x, y, width and height of the live cells
+	Setup      float64  `json:"setup_seconds"`
+	Run        float64  `json:"run_seconds"`
+	Board      string   `json:"board"` // final board file, in the run's directory
+}
+
+// Provenance records how and where a run was made, so that it can be
+// reproduced.
+type Provenance struct {
+	Program        string    `json:"program"`
+	Version        string    `json:"version"`            // module version, or "(devel)"
+	Revision       string    `json:"revision,omitempty"` // VCS revision the binary was built from
+	Modified       bool      `json:"modified,omitempty"` // whether it was built with uncommitted changes
+	GoVersion      string    `json:"go_version"`
+	Platform       string    `json:"platform"`
+	Host           string    `json:"host"`
+	Kernel         string    `json:"kernel,omitempty"` // row kernel, for the bit-sliced engine
+	Manifest       string    `json:"manifest"`
+	ManifestSHA256 string    `json:"manifest_sha256"`
+	Started        time.Time `json:"started"`
+	Finished       time.Time `json:"finished"`
+}
+
+// RunRecord is the file a finished run leaves in its directory.
+type RunRecord struct {
+	Params     RunParams  `json:"params"`
+	Result     RunResult  `json:"result"`
+	Provenance Provenance `json:"provenance"`
+}
+
+// ReadManifest reads a manifest and checks its values.
+func ReadManifest(name string) (*Manifest, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	m := &Manifest{}
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(m.Densities) == 0 {
+		m.Densities = []float64{0.25}
+	}
+	if len(m.Seeds) == 0 {
+		m.Seeds = []int64{1}
+	}
+	if len(m.Engines) == 0 {
+		m.Engines = []string{"step"}
+	}
+	if m.Topology == "" {
+		m.Topology = "torus"
+	}
+	if err := m.check(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) check() error {
+	if len(m.Rules) == 0 || len(m.Sizes) == 0 || len(m.Generations) == 0 {
+		return errors.New("rules, sizes and generations are required")
+	}
+	for _, r := range m.Rules {
+		if _, err := ParseRule(r); err != nil {
+			return err
+		}
+	}
+	for _, s := range m.Sizes {
+		if _, _, err := parseSize(s); err != nil {
+			return err
+		}
+	}
+	for _, d := range m.Densities {
+		if d < 0 || d > 1 {
+			return fmt.Errorf("density %g is not between 0 and 1", d)
+		}
+	}
+	for _, g := range m.Generations {
+		if g < 0 {
+			return fmt.Errorf("negative generations %d", g)
+		}
+	}
+	if m.Topology != "torus" && m.Topology != "plane" {
+		return fmt.Errorf("unknown topology %q", m.Topology)
+	}
+	for _, e := range m.Engines {
+		if !engines[e] {
+			return fmt.Errorf("unknown engine %q", e)
+		}
+		if e == "bitsliced" && m.Topology != "torus" {
+			return errors.New("the bitsliced engine runs only on the torus")
+		}
+	}
+	return nil
+}
+
+// parseSize parses a board size such as 64x64.
+func parseSize(s string) (w, h int, err error) {
+	ws, hs, ok := strings.Cut(s, "x")
+	w, err1 := strconv.Atoi(ws)
+	h, err2 := strconv.Atoi(hs)
+	if !ok || err1 != nil || err2 != nil || w < 1 || h < 1 {
+		return 0, 0, fmt.Errorf("malformed board size %q", s)
+	}
+	return w, h, nil
+}
+
+// Runs returns every combination of the manifest's parameters. Values
+// listed more than once give a single run, since the runs would share a
+// directory.
+func (m *Manifest) Runs() []RunParams {
+	var runs []RunParams
+	seen := map[string]bool{}
+	for _, r := range m.Rules {
+		for _, s := range m.Sizes {
+			w, h, _ := parseSize(s)
+			for _, d := range m.Densities {
+				for _, seed := range m.Seeds {
+					for _, g := range m.Generations {
+						for _, e := range m.Engines {
+							topo := m.Topology
+							if e == "hashlife" {
+								topo = "unbounded plane"
+							}
+							p := RunParams{r, w, h, d, seed, g, e, topo}
+							if !seen[p.ID()] {
+								seen[p.ID()] = true
+								runs = append(runs, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return runs
+}
+
+// soup returns the run's starting board: each cell alive with the chance
+// given by the density, drawn from the seed.
+func (p RunParams) soup() *Board {
+	r := rand.New(rand.NewSource(p.Seed))
+	b := NewBoard(p.Width, p.Height)
+	for y := 0; y < p.Height; y++ {
+		for x := 0; x < p.Width; x++ {
+			b.Set(x, y, r.Float64() < p.Density)
+		}
+	}
+	return b
+}
+
+// Execute carries out the run, writing its final board to dir.
+func (p RunParams) Execute(dir string) (RunResult, error) {
+	var res RunResult
+	rule, err := ParseRule(p.Rule)
+	if err != nil {
+		return res, err
+	}
+	start := time.Now()
+	b := p.soup()
+	res.Board = "final.rle"
+	name := filepath.Join(dir, res.Board)
+
+	// run advances the board and finish records the result; only run is
+	// timed.
+	var run, finish func() error
+	fromBoard := func(f *Board) error {
+		res.Population = int64(population(f))
+		x, y, w, h := f.Bounds()
+		res.Bounds = [4]int64{int64(x), int64(y), int64(w), int64(h)}
+		final := &Pattern{Board: f, Rule: rule}
+		final.Gen = NewBigInt(int64(p.Generations))
+		return WritePattern(name, final, defaultLiveBlock, defaultDeadBlock)
+	}
+	switch p.Engine {
+	case "step", "blocked":
+		l := &State{a: b, b: NewBoard(p.Width, p.Height), w: p.Width, h: p.Height, rule: rule}
+		if p.Topology == "plane" {
+			l.SetTopology(Plane)
+		}
+		run = func() error {
+			if p.Engine == "blocked" {
+				l.StepBlocked(p.Generations, 128, 16)
+				return nil
+			}
+			for i := 0; i < p.Generations; i++ {
+				l.Step()
+			}
+			return nil
+		}
+		finish = func() error { return fromBoard(l.a) }
+	case "bitsliced":
+		bb := NewBitBoards(p.Width, p.Height, rule)
+		bb.Load(0, b)
+		run = func() error {
+			for i := 0; i < p.Generations; i++ {
+				bb.Step()
+			}
+			return nil
+		}
+		finish = func() error { return fromBoard(bb.Board(0)) }
+	case "hashlife":
+		u, err := NewUniverse(rule, 1)
+		if err != nil {
+			return res, err
+		}
+		if err := u.Load(b, 0, 0); err != nil {
+			return res, err
+		}
+		run = func() error { return u.Advance(int64(p.Generations)) }
+		finish = func() error {
+			res.Population = u.Population()
+			x, y, w, h := u.Bounds()
+			res.Bounds = [4]int64{x, y, w, h}
+			return writeUniverse(name, u, PatternMeta{})
+		}
+	default:
+		return res, fmt.Errorf("unknown engine %q", p.Engine)
+	}
+	res.Setup = time.Since(start).Seconds()
+	start = time.Now()
+	if err := run(); err != nil {
+		return res, err
+	}
+	res.Run = time.Since(start).Seconds()
+	return res, finish()
+}
+
+// buildProvenance returns what is known about the binary and the machine,
+// for runs of the named manifest.
+func buildProvenance(manifest string) (Provenance, error) {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return Provenance{}, err
+	}
+	sum := sha256.Sum256(data)
+	p := Provenance{
+		Program:        os.Args[0],
+		Version:        "unknown",
+		GoVersion:      runtime.Version(),
+		Platform:       runtime.GOOS + "/" + runtime.GOARCH,
+		Manifest:       manifest,
+		ManifestSHA256: hex.EncodeToString(sum[:]),
+	}
+	if exe, err := os.Executable(); err == nil {
+		p.Program = exe
+	}
+	p.Host, _ = os.Hostname()
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		p.Version = bi.Main.Version
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				p.Revision = s.Value
+			case "vcs.modified":
+				p.Modified = s.Value == "true"
+			}
+		}
+	}
+	return p, nil
+}
+
+// writeJSON writes v to a file as indented JSON. The file is written under
+// another name and renamed into place, so that it exists only once whole.
+func writeJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// readRecord reads a finished run's record, reporting whether there is one.
+func readRecord(name string) (*RunRecord, bool) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	var r RunRecord
+	if json.Unmarshal(data, &r) != nil {
+		return nil, false
+	}
+	return &r, true
+}
+
+// writeSummary writes one line per finished run to a CSV file.
+func writeSummary(name string, recs []*RunRecord) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"id", "rule", "width", "height", "density", "seed", "generations", "engine", "topology",
+		"population", "run_seconds"})
+	for _, r := range recs {
+		p := r.Params
+		w.Write([]string{p.ID(), p.Rule, strconv.Itoa(p.Width), strconv.Itoa(p.Height),
+			strconv.FormatFloat(p.Density, 'g', -1, 64), strconv.FormatInt(p.Seed, 10),
+			strconv.Itoa(p.Generations), p.Engine, p.Topology,
+			strconv.FormatInt(r.Result.Population, 10), strconv.FormatFloat(r.Result.Run, 'f', 6, 64)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runSweep(args []string) error {
+	var (
+		manifest, out string
+		workers       int
+	)
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.StringVar(&manifest, "manifest", "", "JSON manifest listing the rules, sizes, densities, seeds, generations and engines to combine")
+	fs.StringVar(&out, "out", "sweep", "directory to write a subdirectory per run and a summary.csv into")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "runs to carry out at once")
+	fs.Parse(args)
+	if manifest == "" {
+		return errors.New("sweep needs -manifest")
+	}
+	m, err := ReadManifest(manifest)
+	if err != nil {
+		return err
+	}
+	prov, err := buildProvenance(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+
+	// Runs whose directory already holds a record are skipped, so an
+	// interrupted sweep picks up where it stopped.
+	runs := m.Runs()
+	recs := make([]*RunRecord, len(runs))
+	var todo []int
+	for i, p := range runs {
+		if r, ok := readRecord(filepath.Join(out, p.ID(), "run.json")); ok {
+			recs[i] = r
+		} else {
+			todo = append(todo, i)
+		}
+	}
+	fmt.Printf("%d runs, %d already done\n", len(runs), len(runs)-len(todo))
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	jobs := make(chan int)
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p := runs[i]
+				dir := filepath.Join(out, p.ID())
+				rec := &RunRecord{Params: p, Provenance: prov}
+				if p.Engine == "bitsliced" {
+					rec.Provenance.Kernel = rowKernels[len(rowKernels)-1].name
+				}
+				rec.Provenance.Started = time.Now().UTC()
+				err := os.MkdirAll(dir, 0o755)
+				if err == nil {
+					rec.Result, err = p.Execute(dir)
+				}
+				rec.Provenance.Finished = time.Now().UTC()
+				if err == nil {
+					err = writeJSON(filepath.Join(dir, "run.json"), rec)
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+					fmt.Printf("%s: %v\n", p.ID(), err)
+				} else {
+					recs[i] = rec
+					fmt.Printf("%s: population %d in %.3fs\n", p.ID(), rec.Result.Population, rec.Result.Run)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range todo {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var done []*RunRecord
+	for _, r := range recs {
+		if r != nil {
+			done = append(done, r)
+		}
+	}
+	if err := writeSummary(filepath.Join(out, "summary.csv"), done); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs failed; run the sweep again to retry them", failed, len(runs))
+	}
+	fmt.Printf("wrote %s\n", filepath.Join(out, "summary.csv"))
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"image/color"
+	"math/rand"
+)
+
+// States of a ForestFire cell.
+const (
+	fireEmpty = iota
+	fireTree
+	fireBurning
+)
+
+// ForestFireRule parameterizes the Drossel–Schwabl forest-fire model.
+type ForestFireRule struct {
+	Growth    float64 // probability that a tree grows on an empty cell
+	Lightning float64 // probability that lightning ignites a tree
+	Moore     bool    // fire spreads diagonally as well as orthogonally
+}
+
+// ForestFire is the Drossel–Schwabl forest-fire model. A burning cell burns
+// out and becomes empty, a tree catches fire if a neighbor is burning or it
+// is struck by lightning, and a tree may grow on an empty cell.
+type ForestFire struct {
+	a, b *Grid
+	rule ForestFireRule
+	r    *rand.Rand
+}
+
+// NewForestFire returns a forest of the given size in which each cell holds
+// a tree with probability density.
+func NewForestFire(w, h int, rule ForestFireRule, density float64, r *rand.Rand) *ForestFire {
+	a := NewGrid(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if r.Float64() < density {
+				a.Set(x, y, fireTree)
+			}
+		}
+	}
+	return &ForestFire{a: a, b: NewGrid(w, h), rule: rule, r: r}
+}
+
+// Step advances the forest by one generation.
+func (f *ForestFire) Step() {
+	for y := 0; y < f.a.h; y++ {
+		for x := 0; x < f.a.w; x++ {
+			v := f.a.Get(x, y)
+			switch v {
+			case fireBurning:
+				v = fireEmpty
+			case fireTree:
+				if f.a.Count(x, y, 1, f.rule.Moore, fireBurning) > 0 || f.r.Float64() < f.rule.Lightning {
+					v = fireBurning
+				}
+			case fireEmpty:
+				if f.r.Float64() < f.rule.Growth {
+					v = fireTree
+				}
+			}
+			f.b.Set(x, y, v)
+		}
+	}
+	f.a, f.b = f.b, f.a
+}
+
+// Census returns the number of empty, tree and burning cells.
+func (f *ForestFire) Census() []int {
+	return f.a.Census(3)
+}
+
+// Clusters returns the sizes of the connected clusters of trees.
+func (f *ForestFire) Clusters() []int {
+	return f.a.Clusters(fireTree, f.rule.Moore)
+}
+
+var firePalette = []color.RGBA{
+	fireEmpty:   {0, 0, 0, 255},
+	fireTree:    {30, 140, 40, 255},
+	fireBurning: {255, 120, 0, 255},
+}
+
+// String returns the forest colored by state.
+func (f *ForestFire) String() string {
+	return ansiGrid(f.a, firePalette)
+}
+
+func runForestFire(args []string) error {
+	var (
+		sf      simFlags
+		rule    ForestFireRule
+		density float64
+		out     string
+		quiet   bool
+	)
+	fs := flag.NewFlagSet("fire", flag.ExitOnError)
+	sf.register(fs)
+	fs.Float64Var(&rule.Growth, "p", 0.05, "probability of a tree growing on an empty cell")
+	fs.Float64Var(&rule.Lightning, "f", 0.0001, "probability of lightning striking a tree")
+	fs.BoolVar(&rule.Moore, "moore", false, "spread fire to diagonal neighbors too")
+	fs.Float64Var(&density, "density", 0, "initial density of trees")
+	fs.StringVar(&out, "csv", "", "write per-generation counts to this CSV file")
+	fs.BoolVar(&quiet, "quiet", false, "do not draw the forest")
+	fs.Parse(args)
+	f := NewForestFire(sf.w, sf.h, rule, density, sf.rand())
+	return runCompartments(f, []string{"empty", "tree", "burning"}, sf, out, quiet)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// galleryItem is a pattern shown in the web gallery: a catalog entry or a
+// soup search find.
+type galleryItem struct {
+	*Pattern
+	ID     string
+	Kind   string
+	Period int
+	Source string
+}
+
+// gallery serves the catalog and the soup search finds recorded in the
+// findings file. The items are parsed once and parsed again only when the
+// findings file changes, so new finds show up.
+type gallery struct {
+	findings string
+
+	mu      sync.Mutex
+	catalog []galleryItem // parsed catalog entries, which never change
+	cache   []galleryItem
+	byID    map[string]int // index into cache
+	mod     time.Time      // modification time and size of the findings file read
+	size    int64
+	loaded  bool
+}
+
+// slug turns a name into a URL path element.
+func slug(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+}
+
+// items returns every pattern in the gallery, and a map from their IDs to
+// their indices. The slice is shared and must not be modified.
+func (g *gallery) items() ([]galleryItem, map[string]int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.catalog == nil {
+		for _, e := range catalog {
+			p, err := e.Pattern()
+			if err != nil {
+				return nil, nil, err
+			}
+			g.catalog = append(g.catalog, galleryItem{
+				Pattern: p, ID: slug(e.Name), Kind: e.Kind, Period: e.Period, Source: "catalog",
+			})
+		}
+	}
+	var mod time.Time
+	var size int64
+	if g.findings != "" {
+		fi, err := os.Stat(g.findings)
+		if err == nil {
+			mod, size = fi.ModTime(), fi.Size()
+		} else if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	if g.loaded && mod.Equal(g.mod) && size == g.size {
+		return g.cache, g.byID, nil
+	}
+	finds, err := g.readFindings()
+	if err != nil {
+		return nil, nil, err
+	}
+	items := append(append([]galleryItem(nil), g.catalog...), finds...)
+	g.byID = make(map[string]int, len(items))
+	for i, it := range items {
+		g.byID[it.ID] = i
+	}
+	g.cache, g.mod, g.size, g.loaded = items, mod, size, true
+	return g.cache, g.byID, nil
+}
+
+// readFindings parses the soup search finds in the findings file. A seed
+// logged more than once gets a numbered ID for each later find.
+func (g *gallery) readFindings() ([]galleryItem, error) {
+	if g.findings == "" {
+		return nil, nil
+	}
+	f, err := os.Open(g.findings)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var items []galleryItem
+	seen := map[int64]int{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var p WebhookPayload
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("%s: %v", g.findings, err)
+		}
+		pat, err := ParseRLE(p.RLE)
+		if err != nil {
+			return nil, fmt.Errorf("%s: seed %d: %v", g.findings, p.Seed, err)
+		}
+		if pat.Name == "" {
+			pat.Name = fmt.Sprintf("%s from soup %d", p.Object, p.Seed)
+		}
+		period, _ := strconv.Atoi(strings.TrimPrefix(p.Object, "p"))
+		id := fmt.Sprintf("soup-%d", p.Seed)
+		if n := seen[p.Seed]; n > 0 {
+			id = fmt.Sprintf("soup-%d-%d", p.Seed, n+1)
+		}
+		seen[p.Seed]++
+		items = append(items, galleryItem{
+			Pattern: pat,
+			ID:      id,
+			Kind:    "soup find",
+			Period:  period,
+			Source:  fmt.Sprintf("soup search, seed %d, generation %d", p.Seed, p.Generation),
+		})
+	}
+	return items, sc.Err()
+}
+
+// item returns the pattern with the given ID.
+func (g *gallery) item(id string) (galleryItem, error) {
+	items, byID, err := g.items()
+	if err != nil {
+		return galleryItem{}, err
+	}
+	if i, ok := byID[id]; ok {
+		return items[i], nil
+	}
+	return galleryItem{}, os.ErrNotExist
+}
+
+// boardImage draws b with a one-cell margin, about size pixels across, in
+// the colors of theme. Cells are squares of whole pixels where they fit;
+// larger boards are scaled down, each pixel covering a square of cells and
+// lit if any of them is alive.
+func boardImage(b *Board, size int, theme viewerTheme) *image.Paletted {
+	pal := color.Palette{theme.Background, theme.Alive}
+	side := max(b.w, b.h) + 2
+	if cell := size / side; cell >= 1 {
+		img := image.NewPaletted(image.Rect(0, 0, (b.w+2)*cell, (b.h+2)*cell), pal)
+		for y := 0; y < b.h; y++ {
+			for x := 0; x < b.w; x++ {
+				if !b.Active(x, y) {
+					continue
+				}
+				for j := 0; j < cell; j++ {
+					for i := 0; i < cell; i++ {
+						img.SetColorIndex((x+1)*cell+i, (y+1)*cell+j, 1)
+					}
+				}
+			}
+		}
+		return img
+	}
+	per := (side + size - 1) / size // cells per pixel
+	img := image.NewPaletted(image.Rect(0, 0, (b.w+2+per-1)/per, (b.h+2+per-1)/per), pal)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if b.Active(x, y) {
+				img.SetColorIndex((x+1)/per, (y+1)/per, 1)
+			}
+		}
+	}
+	return img
+}
+
+// serveImage renders the pattern named in the request path as a PNG about
+// size pixels across.
+func (g *gallery) serveImage(w http.ResponseWriter, r *http.Request, prefix string, size int) {
+	it, err := g.item(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), ".png"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, boardImage(it.Board, size, it.Viewer().theme()))
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		http.NotFound(w, nil)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+var galleryTemplates = template.Must(template.New("").Parse(`
+{{define "head"}}<!doctype html>
+<html><head><meta charset="utf-8"><title>{{.}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.card { display: inline-block; width: 160px; margin: 0 1em 1em 0; vertical-align: top; }
+.card img { width: 128px; height: 128px; object-fit: contain; border: 1px solid #ccc; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+</style></head><body>{{end}}
+
+{{define "index"}}{{template "head" "Pattern gallery"}}
+<h1>Pattern gallery</h1>
+<form>
+<label>Type <select name="kind"><option value="">any</option>
+{{range .Kinds}}<option{{if eq . $.Kind}} selected{{end}}>{{.}}</option>{{end}}
+</select></label>
+<label>Period <input name="period" size="4" value="{{.Period}}"></label>
+<label>Rule <input name="rule" size="12" value="{{.Rule}}"></label>
+<button>Filter</button>
+</form>
+<p>{{len .Items}} patterns</p>
+{{range .Items}}<div class="card"><a href="/pattern/{{.ID}}"><img src="/thumb/{{.ID}}.png" alt=""><br>{{.Name}}</a><br>
+<small>{{.Kind}}{{if .Period}}, p{{.Period}}{{end}}, {{.Rule}}</small></div>
+{{end}}</body></html>{{end}}
+
+{{define "detail"}}{{template "head" .Name}}
+<p><a href="/">Gallery</a></p>
+<h1>{{.Name}}</h1>
+<p>{{.Kind}}{{if .Period}}, period {{.Period}}{{end}}, rule {{.Rule}}</p>
+<p>Source: {{.Source}}</p>
+{{if .Author}}<p>Author: {{.Author}}</p>{{end}}
+{{if .Discovered}}<p>Discovered: {{.Discovered}}</p>{{end}}
+{{range .Comments}}<p>{{.}}</p>
+{{end}}{{if .URLs}}<ul>{{range .URLs}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}
+<p><img src="/image/{{.ID}}.png" alt=""></p>
+<p><a href="/live/{{.ID}}">Open in the live viewer</a></p>
+<pre>{{.RLE}}</pre>
+</body></html>{{end}}
+
+{{define "live"}}{{template "head" .Name}}
+<p><a href="/pattern/{{.ID}}">{{.Name}}</a></p>
+<canvas id="board"></canvas>
+<p><button id="run"></button> <button id="step">Step</button> generation <span id="gen">0</span></p>
+<script>
+const data = {{.Data}};
+const w = data.W, h = data.H, size = data.Zoom || Math.max(2, Math.floor(640 / Math.max(w, h)));
+let cells = new Uint8Array(w * h), next = new Uint8Array(w * h), gen = 0, running = data.Running;
+for (const [x, y] of data.Cells) cells[y * w + x] = 1;
+const canvas = document.getElementById("board"), ctx = canvas.getContext("2d");
+canvas.width = Math.min(w * size, 640); canvas.height = Math.min(h * size, 480);
+// The view is centered on the cell (cx, cy) of the field.
+const cx = data.CX, cy = data.CY;
+function draw() {
+  ctx.fillStyle = data.Background; ctx.fillRect(0, 0, canvas.width, canvas.height);
+  ctx.fillStyle = data.Alive;
+  for (let i = 0; i < w * h; i++) if (cells[i])
+    ctx.fillRect(canvas.width / 2 + (i % w - cx) * size, canvas.height / 2 + (Math.floor(i / w) - cy) * size, size, size);
+  document.getElementById("gen").textContent = gen;
+}
+function step() {
+  for (let s = 0; s < data.Step; s++) {
+    for (let y = 0; y < h; y++) for (let x = 0; x < w; x++) {
+      let n = 0;
+      for (let j = -1; j <= 1; j++) for (let i = -1; i <= 1; i++)
+        if (i || j) n += cells[(y + j + h) % h * w + (x + i + w) % w];
+      next[y * w + x] = (cells[y * w + x] ? data.Survive : data.Birth) >> n & 1;
+    }
+    [cells, next] = [next, cells]; gen++;
+  }
+  draw();
+}
+const run = document.getElementById("run");
+run.textContent = running ? "Pause" : "Run";
+run.onclick = () => { running = !running; run.textContent = running ? "Pause" : "Run"; };
+document.getElementById("step").onclick = step;
+setInterval(() => { if (running) step(); }, 1000 / data.GPS);
+draw();
+</script></body></html>{{end}}
+`))
+
+// liveData is the pattern handed to the live viewer's script: a toroidal
+// field with the pattern in the middle, the rule as bit masks, and the
+// display settings from the pattern's LifeViewer script.
+type liveData struct {
+	W, H              int
+	Cells             [][2]int
+	Birth, Survive    uint16
+	Zoom              float64 // cell size in pixels, or 0 to fit the field
+	CX, CY            float64 // field cell at the center of the view
+	GPS               float64
+	Step              int
+	Running           bool
+	Background, Alive string
+}
+
+func (g *gallery) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	items, _, err := g.items()
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	data := struct {
+		Items              []galleryItem
+		Kinds              []string
+		Kind, Period, Rule string
+	}{Kind: q.Get("kind"), Period: q.Get("period"), Rule: q.Get("rule")}
+	period, perr := strconv.Atoi(data.Period)
+	rule, rerr := ParseRule(data.Rule)
+	kinds := map[string]bool{}
+	for _, it := range items {
+		kinds[it.Kind] = true
+		if data.Kind != "" && it.Kind != data.Kind ||
+			perr == nil && it.Period != period ||
+			rerr == nil && it.Rule != rule {
+			continue
+		}
+		data.Items = append(data.Items, it)
+	}
+	for k := range kinds {
+		data.Kinds = append(data.Kinds, k)
+	}
+	sort.Strings(data.Kinds)
+	if err := galleryTemplates.ExecuteTemplate(w, "index", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (g *gallery) serveDetail(w http.ResponseWriter, r *http.Request) {
+	it, err := g.item(strings.TrimPrefix(r.URL.Path, "/pattern/"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if err := galleryTemplates.ExecuteTemplate(w, "detail", it); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (g *gallery) serveLive(w http.ResponseWriter, r *http.Request) {
+	it, err := g.item(strings.TrimPrefix(r.URL.Path, "/live/"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	b := it.Board
+	v := it.Viewer()
+	d := liveData{
+		W: max(64, b.w+40), H: max(48, b.h+40),
+		Birth: it.Rule.Birth, Survive: it.Rule.Survive,
+		Zoom: v.Zoom, GPS: 10, Step: v.steps(), Running: v.running(true),
+		Background: hexColor(v.theme().Background), Alive: hexColor(v.theme().Alive),
+	}
+	if v.GPS > 0 {
+		d.GPS = v.GPS
+	}
+	ox, oy := (d.W-b.w)/2, (d.H-b.h)/2
+	d.CX, d.CY = float64(ox)+float64(b.w)/2+v.X, float64(oy)+float64(b.h)/2+v.Y
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			if b.Active(x, y) {
+				d.Cells = append(d.Cells, [2]int{ox + x, oy + y})
+			}
+		}
+	}
+	page := struct {
+		galleryItem
+		Data liveData
+	}{it, d}
+	if err := galleryTemplates.ExecuteTemplate(w, "live", page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func runServe(args []string) error {
+	var (
+		addr string
+		g    gallery
+	)
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	fs.StringVar(&g.findings, "findings", "", "soup search findings file written by soup -findings")
+	fs.Parse(args)
+	if _, _, err := g.items(); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", g.serveIndex)
+	mux.HandleFunc("/pattern/", g.serveDetail)
+	mux.HandleFunc("/live/", g.serveLive)
+	mux.HandleFunc("/thumb/", func(w http.ResponseWriter, r *http.Request) {
+		g.serveImage(w, r, "/thumb/", 128)
+	})
+	mux.HandleFunc("/image/", func(w http.ResponseWriter, r *http.Request) {
+		g.serveImage(w, r, "/image/", 512)
+	})
+	fmt.Printf("serving the gallery on http://%s/\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
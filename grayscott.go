@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GrayScottParams are the parameters of the Gray–Scott model.
+type GrayScottParams struct {
+	Du, Dv float64 // diffusion rates of U and V
+	F, K   float64 // feed and kill rates
+	DT     float64 // integration time step
+}
+
+// grayScottPresets are named (F, K) regimes from Pearson's classification,
+// used with the default diffusion rates and time step.
+var grayScottPresets = map[string]GrayScottParams{
+	"chaos":    {F: 0.026, K: 0.051},
+	"coral":    {F: 0.0545, K: 0.062},
+	"holes":    {F: 0.039, K: 0.058},
+	"maze":     {F: 0.029, K: 0.057},
+	"mitosis":  {F: 0.0367, K: 0.0649},
+	"solitons": {F: 0.030, K: 0.062},
+	"waves":    {F: 0.014, K: 0.045},
+	"worms":    {F: 0.078, K: 0.061},
+}
+
+// MaxDT returns the largest time step for which explicit integration of the
+// diffusion terms is stable on a unit grid.
+func (p GrayScottParams) MaxDT() float64 {
+	d := p.Du
+	if p.Dv > d {
+		d = p.Dv
+	}
+	return 1 / (4 * d)
+}
+
+// Check reports an error if the parameters are out of range or the time
+// step would make the integration unstable.
+func (p GrayScottParams) Check() error {
+	switch {
+	case p.Du <= 0 || p.Dv <= 0:
+		return fmt.Errorf("diffusion rates must be positive, got %g and %g", p.Du, p.Dv)
+	case p.F < 0 || p.K < 0:
+		return fmt.Errorf("feed and kill rates must not be negative, got %g and %g", p.F, p.K)
+	case p.DT <= 0:
+		return fmt.Errorf("time step must be positive, got %g", p.DT)
+	case p.DT > p.MaxDT():
+		return fmt.Errorf("time step %g is unstable; must be at most %g", p.DT, p.MaxDT())
+	}
+	return nil
+}
+
+// GrayScott is a Gray–Scott reaction–diffusion system of two chemicals,
+// U and V, on a toroidal grid:
+//
+//	∂u/∂t = Du∇²u − uv² + F(1−u)
+//	∂v/∂t = Dv∇²v + uv² − (F+K)v
+//
+// integrated with the forward Euler method and a five-point Laplacian.
+type GrayScott struct {
+	u, v   []float64
+	u2, v2 []float64
+	w, h   int
+	p      GrayScottParams
+}
+
+// NewGrayScott returns a field of the given size filled with U and free of V.
+func NewGrayScott(w, h int, p GrayScottParams) (*GrayScott, error) {
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	g := &GrayScott{
+		u: make([]float64, w*h), v: make([]float64, w*h),
+		u2: make([]float64, w*h), v2: make([]float64, w*h),
+		w: w, h: h,
+		p: p,
+	}
+	for i := range g.u {
+		g.u[i] = 1
+	}
+	return g, nil
+}
+
+// Seed perturbs the field wherever b is active, which must have the same
+// size, adding a little noise from r to break symmetry.
+func (g *GrayScott) Seed(b *Board, r *rand.Rand) {
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			if b.Active(x, y) {
+				i := y*g.w + x
+				g.u[i] = 0.5 + 0.1*r.Float64()
+				g.v[i] = 0.25 + 0.1*r.Float64()
+			}
+		}
+	}
+}
+
+// Step advances the system by one time step.
+func (g *GrayScott) Step() {
+	p := g.p
+	for y := 0; y < g.h; y++ {
+		up := (y + g.h - 1) % g.h * g.w
+		row := y * g.w
+		down := (y + 1) % g.h * g.w
+		for x := 0; x < g.w; x++ {
+			left := (x + g.w - 1) % g.w
+			right := (x + 1) % g.w
+			i := row + x
+			u, v := g.u[i], g.v[i]
+			lu := g.u[row+left] + g.u[row+right] + g.u[up+x] + g.u[down+x] - 4*u
+			lv := g.v[row+left] + g.v[row+right] + g.v[up+x] + g.v[down+x] - 4*v
+			uvv := u * v * v
+			g.u2[i] = u + p.DT*(p.Du*lu-uvv+p.F*(1-u))
+			g.v2[i] = v + p.DT*(p.Dv*lv+uvv-(p.F+p.K)*v)
+		}
+	}
+	g.u, g.u2 = g.u2, g.u
+	g.v, g.v2 = g.v2, g.v
+}
+
+// V returns the concentration of V at the specified cell.
+func (g *GrayScott) V(x, y int) float64 {
+	return g.v[y*g.w+x]
+}
+
+// at returns the color of the specified cell. V rarely exceeds one half,
+// so its concentration is doubled to use the whole color scale.
+func (g *GrayScott) at(x, y int) color.RGBA {
+	return colormap(2 * g.V(x, y))
+}
+
+// String returns the field colored by the concentration of V.
+func (g *GrayScott) String() string {
+	return ansiCells(g.w, g.h, g.at)
+}
+
+// Image returns the field colored by the concentration of V, with each cell
+// drawn as a scale×scale square.
+func (g *GrayScott) Image(scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, g.w*scale, g.h*scale))
+	for y := 0; y < g.h*scale; y++ {
+		for x := 0; x < g.w*scale; x++ {
+			img.SetRGBA(x, y, g.at(x/scale, y/scale))
+		}
+	}
+	return img
+}
+
+// writePNG encodes img to the named file.
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runGrayScott(args []string) error {
+	var (
+		sf     simFlags
+		p      GrayScottParams
+		preset string
+		life   int
+		every  int
+		out    string
+		scale  int
+	)
+	names := make([]string, 0, len(grayScottPresets))
+	for name := range grayScottPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fs := flag.NewFlagSet("gs", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&preset, "preset", "", "named regime: "+strings.Join(names, ", "))
+	fs.Float64Var(&p.F, "feed", 0.0545, "feed rate")
+	fs.Float64Var(&p.K, "kill", 0.062, "kill rate")
+	fs.Float64Var(&p.Du, "du", 0.16, "diffusion rate of U")
+	fs.Float64Var(&p.Dv, "dv", 0.08, "diffusion rate of V")
+	fs.Float64Var(&p.DT, "dt", 1, "time step")
+	fs.IntVar(&life, "life", 10, "seed from a random Life soup run for this many generations")
+	fs.IntVar(&every, "every", 20, "time steps per printed frame")
+	fs.StringVar(&out, "png", "", "write the final field to this PNG file")
+	fs.IntVar(&scale, "scale", 4, "pixels per cell in the PNG")
+	fs.Parse(args)
+	if preset != "" {
+		q, ok := grayScottPresets[preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q", preset)
+		}
+		// Rates given explicitly override the preset's.
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["feed"] {
+			p.F = q.F
+		}
+		if !set["kill"] {
+			p.K = q.K
+		}
+	}
+	if scale < 1 {
+		return fmt.Errorf("scale must be positive, got %d", scale)
+	}
+	g, err := NewGrayScott(sf.w, sf.h, p)
+	if err != nil {
+		return err
+	}
+	r := sf.rand()
+	l := NewStateRand(sf.w, sf.h, r)
+	for i := 0; i < life; i++ {
+		l.Step()
+	}
+	g.Seed(l.a, r)
+	animate(skip{g, every}, sf.n, sf.delay)
+	if out == "" {
+		return nil
+	}
+	return writePNG(out, g.Image(scale))
+}
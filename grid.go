@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Grid is a multi-state variant of Board: each cell holds a small integer
+// state rather than a single bit.
+type Grid struct {
+	s    [][]uint8
+	w, h int
+}
+
+// NewGrid returns a field of the specified width and height with every cell
+// in state 0.
+func NewGrid(w, h int) *Grid {
+	s := make([][]uint8, h)
+	for i := range s {
+		s[i] = make([]uint8, w)
+	}
+	return &Grid{s: s, w: w, h: h}
+}
+
+// Set sets the state of the specified cell to v.
+func (g *Grid) Set(x, y int, v uint8) {
+	g.s[y][x] = v
+}
+
+// Get returns the state of the specified cell.
+// Coordinates outside the field are wrapped toroidally, as in Board.Active.
+func (g *Grid) Get(x, y int) uint8 {
+	x = (x%g.w + g.w) % g.w
+	y = (y%g.h + g.h) % g.h
+	return g.s[y][x]
+}
+
+// Randomize sets every cell to a state drawn uniformly from [0, n).
+func (g *Grid) Randomize(n int, r *rand.Rand) {
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			g.s[y][x] = uint8(r.Intn(n))
+		}
+	}
+}
+
+// Count returns the number of cells within distance rng of (x, y), excluding
+// the cell itself, that are in state v. Distance is measured with the
+// Chebyshev metric (Moore neighborhood) if moore is set and the Manhattan
+// metric (von Neumann neighborhood) otherwise.
+func (g *Grid) Count(x, y, rng int, moore bool, v uint8) int {
+	n := 0
+	for j := -rng; j <= rng; j++ {
+		for i := -rng; i <= rng; i++ {
+			if i == 0 && j == 0 || !moore && abs(i)+abs(j) > rng {
+				continue
+			}
+			if g.Get(x+i, y+j) == v {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Census returns the number of cells in each of the states 0 to n-1.
+func (g *Grid) Census(n int) []int {
+	c := make([]int, n)
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			if v := int(g.s[y][x]); v < n {
+				c[v]++
+			}
+		}
+	}
+	return c
+}
+
+// Clusters returns the sizes of the connected clusters of cells in state v.
+// Cells are connected through their Moore neighborhood if moore is set and
+// their von Neumann neighborhood otherwise; clusters may wrap around the
+// edges of the field.
+func (g *Grid) Clusters(v uint8, moore bool) []int {
+	var sizes []int
+	seen := make([]bool, g.w*g.h)
+	var stack []int
+	for start := range seen {
+		if seen[start] || g.s[start/g.w][start%g.w] != v {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		n := 0
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			n++
+			x, y := i%g.w, i/g.w
+			for j := -1; j <= 1; j++ {
+				for k := -1; k <= 1; k++ {
+					if j == 0 && k == 0 || !moore && j != 0 && k != 0 {
+						continue
+					}
+					nx, ny := (x+k+g.w)%g.w, (y+j+g.h)%g.h
+					ni := ny*g.w + nx
+					if !seen[ni] && g.s[ny][nx] == v {
+						seen[ni] = true
+						stack = append(stack, ni)
+					}
+				}
+			}
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
+
+// checkStates reports an error if n states do not fit in a Grid cell.
+func checkStates(name string, n int) error {
+	if n < 2 || n > 256 {
+		return fmt.Errorf("%s must be between 2 and 256, got %d", name, n)
+	}
+	return nil
+}
+
+// checkNeighborhood reports an error if a neighborhood of radius rng does
+// not fit in a w×h field without wrapping onto itself, or has fewer than
+// threshold cells.
+func checkNeighborhood(w, h, rng int, moore bool, threshold int) error {
+	if rng < 1 || 2*rng+1 > min(w, h) {
+		return fmt.Errorf("range must be at least 1 and at most %d on a %d×%d board, got %d", (min(w, h)-1)/2, w, h, rng)
+	}
+	size := 2 * rng * (rng + 1)
+	if moore {
+		size = (2*rng+1)*(2*rng+1) - 1
+	}
+	if threshold < 1 || threshold > size {
+		return fmt.Errorf("threshold must be between 1 and %d, got %d", size, threshold)
+	}
+	return nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
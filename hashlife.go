@@ -0,0 +1,775 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// node is a square quadtree node of side 2^level. Nodes are hash-consed: a
+// nodeTable never holds two nodes with the same children, so equal regions
+// are the same node and results computed for one serve for all.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint8
+	pop            int64  // saturating at math.MaxInt64
+	id             uint64 // unique per table, used to pick a shard
+}
+
+// tableShards is the number of independently locked shards in a nodeTable.
+const tableShards = 64
+
+// nodeTable hash-conses nodes and memoizes their successors. It is split
+// into shards, each with its own lock, so that concurrent workers rarely
+// contend.
+type nodeTable struct {
+	shards [tableShards]struct {
+		sync.Mutex
+		nodes map[[4]*node]*node
+		next  map[nextKey]*node
+	}
+	ids   atomic.Uint64
+	leaf  [2]*node // dead and alive cells
+	empty []*node  // empty node of each level
+	rule  Rule
+}
+
+// nextKey identifies a memoized successor: node n advanced 2^j generations.
+type nextKey struct {
+	n *node
+	j uint8
+}
+
+// maxLevel is the largest node level. It leaves room for the levels that
+// stepPow2 computes to stay within a uint8.
+const maxLevel = 250
+
+// smallLevel is the largest node level whose cell coordinates, relative to
+// the node, fit in an int64.
+const smallLevel = 62
+
+func newNodeTable(rule Rule) *nodeTable {
+	t := &nodeTable{rule: rule}
+	for i := range t.shards {
+		t.shards[i].nodes = make(map[[4]*node]*node)
+		t.shards[i].next = make(map[nextKey]*node)
+	}
+	t.leaf[0] = &node{id: t.ids.Add(1)}
+	t.leaf[1] = &node{pop: 1, id: t.ids.Add(1)}
+	t.empty = append(t.empty, t.leaf[0])
+	for l := 1; l <= maxLevel; l++ {
+		e := t.empty[l-1]
+		t.empty = append(t.empty, t.join(e, e, e, e))
+	}
+	return t
+}
+
+// shard returns the shard responsible for a node with the given children.
+func (t *nodeTable) shard(a, b, c, d *node) int {
+	h := a.id*0x9e3779b97f4a7c15 ^ b.id*0xbf58476d1ce4e5b9 ^ c.id*0x94d049bb133111eb ^ d.id
+	return int((h ^ h>>29) % tableShards)
+}
+
+// join returns the canonical node with the given quadrants.
+func (t *nodeTable) join(nw, ne, sw, se *node) *node {
+	k := [4]*node{nw, ne, sw, se}
+	s := &t.shards[t.shard(nw, ne, sw, se)]
+	s.Lock()
+	defer s.Unlock()
+	if n, ok := s.nodes[k]; ok {
+		return n
+	}
+	n := &node{
+		nw: nw, ne: ne, sw: sw, se: se,
+		level: nw.level + 1,
+		pop:   satAdd(satAdd(nw.pop, ne.pop), satAdd(sw.pop, se.pop)),
+		id:    t.ids.Add(1),
+	}
+	s.nodes[k] = n
+	return n
+}
+
+// center returns the node of half the size at the center of n.
+func (t *nodeTable) center(n *node) *node {
+	return t.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// base advances a level 2 node by one generation, returning its center.
+func (t *nodeTable) base(n *node) *node {
+	var cells [4][4]bool
+	for i, q := range [4]*node{n.nw, n.ne, n.sw, n.se} {
+		x, y := i%2*2, i/2*2
+		cells[y][x] = q.nw.pop != 0
+		cells[y][x+1] = q.ne.pop != 0
+		cells[y+1][x] = q.sw.pop != 0
+		cells[y+1][x+1] = q.se.pop != 0
+	}
+	var out [4]*node
+	for i := range out {
+		x, y := 1+i%2, 1+i/2
+		count := 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && cells[y+dy][x+dx] {
+					count++
+				}
+			}
+		}
+		mask := t.rule.Birth
+		if cells[y][x] {
+			mask = t.rule.Survive
+		}
+		out[i] = t.leaf[mask>>count&1]
+	}
+	return t.join(out[0], out[1], out[2], out[3])
+}
+
+// parallelLevel is the smallest node level whose subproblems are handed to
+// other workers; below it the coordination costs more than it saves.
+const parallelLevel = 8
+
+// hashlife advances quadtree universes using a nodeTable shared by up to
+// workers goroutines.
+type hashlife struct {
+	t   *nodeTable
+	sem chan struct{} // tokens for goroutines beyond the caller's
+}
+
+// each calls f(i) for i in [0, n), on other goroutines when worker tokens
+// are free and on the calling goroutine otherwise.
+func (h *hashlife) each(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case h.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-h.sem
+					wg.Done()
+				}()
+				f(i)
+			}(i)
+		default:
+			f(i)
+		}
+	}
+	wg.Wait()
+}
+
+// successor returns the center of n, a node of level at least 2, advanced
+// 2^j generations, where j is at most n.level-2.
+func (h *hashlife) successor(n *node, j uint8) *node {
+	t := h.t
+	if n.pop == 0 {
+		return t.empty[n.level-1]
+	}
+	if j > n.level-2 {
+		j = n.level - 2
+	}
+	k := nextKey{n, j}
+	s := &t.shards[t.shard(n, n, n, n)]
+	s.Lock()
+	r, ok := s.next[k]
+	s.Unlock()
+	if ok {
+		return r
+	}
+
+	if n.level == 2 {
+		r = t.base(n)
+	} else {
+		// Advance the nine overlapping subsquares of half the size.
+		sub := [9]*node{
+			n.nw, t.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), n.ne,
+			t.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), t.center(n), t.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne),
+			n.sw, t.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), n.se,
+		}
+		var c [9]*node
+		run := func(i int) { c[i] = h.successor(sub[i], j) }
+		if n.level >= parallelLevel {
+			h.each(9, run)
+		} else {
+			for i := range sub {
+				run(i)
+			}
+		}
+		quad := [4]*node{
+			t.join(c[0], c[1], c[3], c[4]), t.join(c[1], c[2], c[4], c[5]),
+			t.join(c[3], c[4], c[6], c[7]), t.join(c[4], c[5], c[7], c[8]),
+		}
+		var q [4]*node
+		if j == n.level-2 {
+			// Full speed: advance each quadrant a second time.
+			run := func(i int) { q[i] = h.successor(quad[i], j) }
+			if n.level >= parallelLevel {
+				h.each(4, run)
+			} else {
+				for i := range quad {
+					run(i)
+				}
+			}
+		} else {
+			for i := range quad {
+				q[i] = t.center(quad[i])
+			}
+		}
+		r = t.join(q[0], q[1], q[2], q[3])
+	}
+
+	// Another worker may have got here first; it found the same node.
+	s.Lock()
+	s.next[k] = r
+	s.Unlock()
+	return r
+}
+
+// Universe is an unbounded Life-like universe advanced with Gosper's
+// HashLife algorithm. Its quadtree nodes are hash-consed and their futures
+// memoized, so repetitive patterns can be run for astronomically many
+// generations. With more than one worker, independent subsquares are
+// advanced concurrently; the result is the same as with one.
+//
+// Generations and coordinates may outgrow an int64. The int64 methods
+// serve the usual case and those ending in Big take and return BigInts;
+// both work on small values without allocating.
+//
+// Nodes are never freed, so memory grows for as long as the universe runs.
+type Universe struct {
+	h      hashlife
+	root   *node
+	ox, oy BigInt // coordinates of the root's top-left cell
+	gen    BigInt
+}
+
+// NewUniverse returns an empty universe under rule r, advanced by up to
+// workers goroutines at once. Rules with B0 are not supported.
+func NewUniverse(r Rule, workers int) (*Universe, error) {
+	if r.Birth&1 != 0 {
+		return nil, errors.New("hashlife does not support B0 rules")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	t := newNodeTable(r)
+	u := &Universe{
+		h:    hashlife{t: t, sem: make(chan struct{}, workers-1)},
+		root: t.empty[3],
+		ox:   NewBigInt(-4), oy: NewBigInt(-4),
+	}
+	return u, nil
+}
+
+// size returns the side of the root node.
+func (u *Universe) size() BigInt {
+	return pow2(uint(u.root.level))
+}
+
+// small reports whether every coordinate within the root is well inside
+// the range of an int64, so that the int64 fast paths can be used.
+func (u *Universe) small() bool {
+	const lim = 1 << 60
+	ox, okx := u.ox.Int64()
+	oy, oky := u.oy.Int64()
+	return okx && oky && u.root.level <= 60 &&
+		-lim <= ox && ox <= lim && -lim <= oy && oy <= lim
+}
+
+// expand doubles the size of the root, keeping its contents in the middle.
+func (u *Universe) expand() error {
+	if u.root.level == maxLevel {
+		return errors.New("hashlife: universe too large")
+	}
+	t, r, e := u.h.t, u.root, u.h.t.empty[u.root.level-1]
+	u.root = t.join(
+		t.join(e, e, e, r.nw), t.join(e, e, r.ne, e),
+		t.join(e, r.sw, e, e), t.join(r.se, e, e, e),
+	)
+	half := pow2(uint(u.root.level) - 2)
+	u.ox = u.ox.Sub(half)
+	u.oy = u.oy.Sub(half)
+	return nil
+}
+
+// contains reports whether the root covers the specified cell.
+func (u *Universe) contains(x, y BigInt) bool {
+	size := u.size()
+	return x.Cmp(u.ox) >= 0 && x.Cmp(u.ox.Add(size)) < 0 &&
+		y.Cmp(u.oy) >= 0 && y.Cmp(u.oy.Add(size)) < 0
+}
+
+// quads returns the quadrants of n in the order nw, ne, sw, se.
+func (n *node) quads() [4]*node {
+	return [4]*node{n.nw, n.ne, n.sw, n.se}
+}
+
+// quadrant returns the index in quads of the quadrant holding (x, y),
+// relative to a node whose quadrants have side half, and the coordinates
+// relative to that quadrant.
+func quadrant(x, y, half BigInt) (i int, qx, qy BigInt) {
+	if x.Cmp(half) >= 0 {
+		i, x = i+1, x.Sub(half)
+	}
+	if y.Cmp(half) >= 0 {
+		i, y = i+2, y.Sub(half)
+	}
+	return i, x, y
+}
+
+// Set sets the state of the specified cell.
+func (u *Universe) Set(x, y int64, alive bool) error {
+	return u.SetBig(NewBigInt(x), NewBigInt(y), alive)
+}
+
+// SetBig is like Set for coordinates of any size.
+func (u *Universe) SetBig(x, y BigInt, alive bool) error {
+	for !u.contains(x, y) {
+		if err := u.expand(); err != nil {
+			return err
+		}
+	}
+	u.root = u.set(u.root, x.Sub(u.ox), y.Sub(u.oy), alive)
+	return nil
+}
+
+// set returns n with the cell at (x, y) within it set. Nodes small enough
+// for int64 coordinates are left to setSmall.
+func (u *Universe) set(n *node, x, y BigInt, alive bool) *node {
+	if n.level <= smallLevel {
+		return u.setSmall(n, x.n, y.n, alive)
+	}
+	q := n.quads()
+	i, x, y := quadrant(x, y, pow2(uint(n.level)-1))
+	q[i] = u.set(q[i], x, y, alive)
+	return u.h.t.join(q[0], q[1], q[2], q[3])
+}
+
+func (u *Universe) setSmall(n *node, x, y int64, alive bool) *node {
+	t := u.h.t
+	if n.level == 0 {
+		return t.leaf[btoi(alive)]
+	}
+	half := int64(1) << (n.level - 1)
+	nw, ne, sw, se := n.nw, n.ne, n.sw, n.se
+	switch {
+	case x < half && y < half:
+		nw = u.setSmall(nw, x, y, alive)
+	case y < half:
+		ne = u.setSmall(ne, x-half, y, alive)
+	case x < half:
+		sw = u.setSmall(sw, x, y-half, alive)
+	default:
+		se = u.setSmall(se, x-half, y-half, alive)
+	}
+	return t.join(nw, ne, sw, se)
+}
+
+// Get reports whether the specified cell is alive.
+func (u *Universe) Get(x, y int64) bool {
+	return u.GetBig(NewBigInt(x), NewBigInt(y))
+}
+
+// GetBig is like Get for coordinates of any size.
+func (u *Universe) GetBig(bx, by BigInt) bool {
+	if !u.contains(bx, by) {
+		return false
+	}
+	n := u.root
+	bx, by = bx.Sub(u.ox), by.Sub(u.oy)
+	for n.level > smallLevel && n.pop > 0 {
+		var i int
+		i, bx, by = quadrant(bx, by, pow2(uint(n.level)-1))
+		n = n.quads()[i]
+	}
+	x, y := bx.n, by.n
+	for n.level > 0 && n.pop > 0 {
+		half := int64(1) << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n.pop > 0
+}
+
+// Load sets the cells of b with its top-left corner at (x, y).
+func (u *Universe) Load(b *Board, x, y int64) error {
+	return u.LoadBig(b, NewBigInt(x), NewBigInt(y))
+}
+
+// LoadBig is like Load for coordinates of any size.
+func (u *Universe) LoadBig(b *Board, x, y BigInt) error {
+	for by := 0; by < b.h; by++ {
+		for bx := 0; bx < b.w; bx++ {
+			if b.Active(bx, by) {
+				if err := u.SetBig(x.Add(NewBigInt(int64(bx))), y.Add(NewBigInt(int64(by))), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Board returns the w×h region of the universe with its top-left corner
+// at (x, y).
+func (u *Universe) Board(x, y int64, w, h int) *Board {
+	return u.BoardBig(NewBigInt(x), NewBigInt(y), w, h)
+}
+
+// BoardBig is like Board for coordinates of any size.
+func (u *Universe) BoardBig(x, y BigInt, w, h int) *Board {
+	b := NewBoard(w, h)
+	u.fill(b, u.root, u.ox.Sub(x), u.oy.Sub(y))
+	return b
+}
+
+// fill sets the cells of b covered by n, whose top-left corner is at (x, y)
+// relative to b. Once n is small enough for int64 coordinates it is left to
+// fillSmall.
+func (u *Universe) fill(b *Board, n *node, x, y BigInt) {
+	size := pow2(uint(n.level))
+	if n.pop == 0 || x.Cmp(NewBigInt(int64(b.w))) >= 0 || y.Cmp(NewBigInt(int64(b.h))) >= 0 ||
+		x.Add(size).Sign() <= 0 || y.Add(size).Sign() <= 0 {
+		return
+	}
+	if n.level <= smallLevel {
+		u.fillSmall(b, n, x.n, y.n)
+		return
+	}
+	half := pow2(uint(n.level) - 1)
+	u.fill(b, n.nw, x, y)
+	u.fill(b, n.ne, x.Add(half), y)
+	u.fill(b, n.sw, x, y.Add(half))
+	u.fill(b, n.se, x.Add(half), y.Add(half))
+}
+
+func (u *Universe) fillSmall(b *Board, n *node, x, y int64) {
+	size := int64(1) << n.level
+	if n.pop == 0 || x >= int64(b.w) || y >= int64(b.h) || x+size <= 0 || y+size <= 0 {
+		return
+	}
+	if n.level == 0 {
+		b.Set(int(x), int(y), true)
+		return
+	}
+	half := size / 2
+	u.fillSmall(b, n.nw, x, y)
+	u.fillSmall(b, n.ne, x+half, y)
+	u.fillSmall(b, n.sw, x, y+half)
+	u.fillSmall(b, n.se, x+half, y+half)
+}
+
+// cells returns the coordinates of every live cell. The universe must be
+// small enough for them to fit in an int64.
+func (u *Universe) cells() [][2]int64 {
+	var c [][2]int64
+	var walk func(n *node, x, y int64)
+	walk = func(n *node, x, y int64) {
+		if n.pop == 0 {
+			return
+		}
+		if n.level == 0 {
+			c = append(c, [2]int64{x, y})
+			return
+		}
+		half := int64(1) << (n.level - 1)
+		walk(n.nw, x, y)
+		walk(n.ne, x+half, y)
+		walk(n.sw, x, y+half)
+		walk(n.se, x+half, y+half)
+	}
+	walk(u.root, u.ox.n, u.oy.n)
+	return c
+}
+
+// Population returns the number of live cells, or math.MaxInt64 if there
+// are more.
+func (u *Universe) Population() int64 {
+	return u.root.pop
+}
+
+// PopulationBig returns the number of live cells, however many there are.
+func (u *Universe) PopulationBig() BigInt {
+	counts := map[*node]BigInt{}
+	var count func(n *node) BigInt
+	count = func(n *node) BigInt {
+		if n.pop < math.MaxInt64 {
+			return NewBigInt(n.pop)
+		}
+		if c, ok := counts[n]; ok {
+			return c
+		}
+		c := count(n.nw).Add(count(n.ne)).Add(count(n.sw)).Add(count(n.se))
+		counts[n] = c
+		return c
+	}
+	return count(u.root)
+}
+
+// Generation returns the number of generations the universe has advanced.
+func (u *Universe) Generation() BigInt {
+	return u.gen
+}
+
+// Bounds returns the smallest rectangle containing every live cell as its
+// top-left corner and size. An empty universe has zero size. If the
+// rectangle does not fit in an int64 only the low 64 bits are returned;
+// BoundsBig gives it in full.
+func (u *Universe) Bounds() (x, y, w, h int64) {
+	if u.root.pop == 0 {
+		return 0, 0, 0, 0
+	}
+	if !u.small() {
+		bx, by, bw, bh := u.BoundsBig()
+		x, _ = bx.Int64()
+		y, _ = by.Int64()
+		w, _ = bw.Int64()
+		h, _ = bh.Int64()
+		return x, y, w, h
+	}
+	x0, y0, x1, y1 := int64(1)<<62, int64(1)<<62, -int64(1)<<62, -int64(1)<<62
+	var walk func(n *node, x, y int64)
+	walk = func(n *node, x, y int64) {
+		size := int64(1) << n.level
+		if n.pop == 0 || x >= x0 && y >= y0 && x+size <= x1 && y+size <= y1 {
+			return
+		}
+		if n.level == 0 {
+			x0, y0 = min(x0, x), min(y0, y)
+			x1, y1 = max(x1, x+1), max(y1, y+1)
+			return
+		}
+		half := size / 2
+		walk(n.nw, x, y)
+		walk(n.ne, x+half, y)
+		walk(n.sw, x, y+half)
+		walk(n.se, x+half, y+half)
+	}
+	walk(u.root, u.ox.n, u.oy.n)
+	return x0, y0, x1 - x0, y1 - y0
+}
+
+// BoundsBig is like Bounds for universes of any size.
+func (u *Universe) BoundsBig() (x, y, w, h BigInt) {
+	if u.root.pop == 0 {
+		return
+	}
+	var x0, y0, x1, y1 BigInt
+	found := false
+	var walk func(n *node, x, y BigInt)
+	walk = func(n *node, x, y BigInt) {
+		size := pow2(uint(n.level))
+		if n.pop == 0 || found && x.Cmp(x0) >= 0 && y.Cmp(y0) >= 0 &&
+			x.Add(size).Cmp(x1) <= 0 && y.Add(size).Cmp(y1) <= 0 {
+			return
+		}
+		if n.level == 0 {
+			one := NewBigInt(1)
+			if !found {
+				x0, y0, x1, y1, found = x, y, x.Add(one), y.Add(one), true
+			}
+			if x.Cmp(x0) < 0 {
+				x0 = x
+			}
+			if y.Cmp(y0) < 0 {
+				y0 = y
+			}
+			if x.Add(one).Cmp(x1) > 0 {
+				x1 = x.Add(one)
+			}
+			if y.Add(one).Cmp(y1) > 0 {
+				y1 = y.Add(one)
+			}
+			return
+		}
+		half := pow2(uint(n.level) - 1)
+		walk(n.nw, x, y)
+		walk(n.ne, x.Add(half), y)
+		walk(n.sw, x, y.Add(half))
+		walk(n.se, x.Add(half), y.Add(half))
+	}
+	walk(u.root, u.ox, u.oy)
+	return x0, y0, x1.Sub(x0), y1.Sub(y0)
+}
+
+// centered reports whether every live cell of n, a node of level at least
+// 3, lies in its central sixteenth. That is made up of one great-grandchild
+// of each quadrant, at the corner nearest n's center.
+func centered(n *node) bool {
+	for i, q := range n.quads() {
+		inner := 3 - i // index of the quadrant's corner nearest n's center
+		for k, g := range q.quads() {
+			if k != inner && g.pop != 0 {
+				return false
+			}
+		}
+		for k, g := range q.quads()[inner].quads() {
+			if k != inner && g.pop != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stepPow2 advances the universe by 2^j generations.
+func (u *Universe) stepPow2(j uint8) error {
+	// Pad the root until the pattern lies within its central sixteenth, so
+	// that nothing can travel out of the center returned by successor.
+	for u.root.level < j+3 || !centered(u.root) {
+		if err := u.expand(); err != nil {
+			return err
+		}
+	}
+	quarter := pow2(uint(u.root.level) - 2)
+	u.root = u.h.successor(u.root, j)
+	u.ox = u.ox.Add(quarter)
+	u.oy = u.oy.Add(quarter)
+	u.gen = u.gen.Add(pow2(uint(j)))
+	return nil
+}
+
+// Advance steps the universe forward by n generations.
+func (u *Universe) Advance(n int64) error {
+	for j := uint8(0); n > 0; j++ {
+		if n&1 != 0 {
+			if err := u.stepPow2(j); err != nil {
+				return err
+			}
+		}
+		n >>= 1
+	}
+	return nil
+}
+
+// AdvanceBig is like Advance for generation counts of any size.
+func (u *Universe) AdvanceBig(n BigInt) error {
+	if v, ok := n.Int64(); ok {
+		return u.Advance(v)
+	}
+	b := n.Big()
+	for j := 0; j < b.BitLen() && b.Sign() > 0; j++ {
+		if b.Bit(j) == 0 {
+			continue
+		}
+		if j > maxLevel-3 {
+			return errors.New("hashlife: universe too large")
+		}
+		if err := u.stepPow2(uint8(j)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameTree reports whether a and b, from possibly different tables, hold
+// the same cells. Pairs already known to match are recorded in seen.
+func sameTree(a, b *node, seen map[[2]*node]bool) bool {
+	if a.level != b.level || a.pop != b.pop {
+		return false
+	}
+	if a.level == 0 || a.pop == 0 || seen[[2]*node{a, b}] {
+		return true
+	}
+	ok := sameTree(a.nw, b.nw, seen) && sameTree(a.ne, b.ne, seen) &&
+		sameTree(a.sw, b.sw, seen) && sameTree(a.se, b.se, seen)
+	if ok {
+		seen[[2]*node{a, b}] = true
+	}
+	return ok
+}
+
+func runHashLife(args []string) error {
+	var (
+		name    string
+		file    string
+		out     string
+		gens    = NewBigInt(1 << 20)
+		workers int
+		verify  bool
+	)
+	fs := flag.NewFlagSet("hashlife", flag.ExitOnError)
+	fs.StringVar(&name, "pattern", "acorn", "catalog pattern to run")
+	fs.StringVar(&file, "file", "", "run the pattern in this .rle, .cells, .mc or .schem file instead")
+	fs.StringVar(&out, "out", "", "write the result to this pattern file, recording its generation and position")
+	fs.Var(&gens, "gens", "generations to advance, such as 1000000 or 2^100")
+	fs.IntVar(&workers, "workers", 4, "goroutines to advance with")
+	fs.BoolVar(&verify, "verify", false, "also run single-threaded and check the results match")
+	fs.Parse(args)
+
+	var p *Pattern
+	var err error
+	if file != "" {
+		p, err = ReadPattern(file, defaultLiveBlock)
+	} else {
+		p, err = catalogPattern(name)
+	}
+	if err != nil {
+		return err
+	}
+	b, rule := p.Board, p.Rule
+
+	run := func(workers int) (*Universe, error) {
+		u, err := NewUniverse(rule, workers)
+		if err != nil {
+			return nil, err
+		}
+		if err := u.LoadBig(b, p.X, p.Y); err != nil {
+			return nil, err
+		}
+		u.gen = p.Gen
+		start := time.Now()
+		if err := u.AdvanceBig(gens); err != nil {
+			return nil, err
+		}
+		x, y, w, h := u.BoundsBig()
+		fmt.Printf("%d workers: generation %s, population %s, bounds %s×%s at (%s, %s), %v\n",
+			workers, u.Generation().Display(), u.PopulationBig().Display(),
+			w.Display(), h.Display(), x.Display(), y.Display(), time.Since(start).Round(time.Millisecond))
+		return u, nil
+	}
+	u, err := run(workers)
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		if err := writeUniverse(out, u, p.PatternMeta); err != nil {
+			return err
+		}
+	}
+	if !verify {
+		return nil
+	}
+	v, err := run(1)
+	if err != nil {
+		return err
+	}
+	if u.ox.Cmp(v.ox) != 0 || u.oy.Cmp(v.oy) != 0 || !sameTree(u.root, v.root, map[[2]*node]bool{}) {
+		return errors.New("parallel and sequential results differ")
+	}
+	fmt.Println("results match")
+	return nil
+}
+
+// writeUniverse writes the live cells of u to a pattern file, with the
+// given metadata and u's generation and position.
+func writeUniverse(name string, u *Universe, meta PatternMeta) error {
+	x, y, w, h := u.BoundsBig()
+	if limit := NewBigInt(1 << 16); w.Cmp(limit) > 0 || h.Cmp(limit) > 0 {
+		return fmt.Errorf("%s: pattern is too large for a board (%s×%s)", name, w.Display(), h.Display())
+	}
+	p := &Pattern{PatternMeta: meta, Board: u.BoardBig(x, y, int(w.n), int(h.n)), Rule: u.h.t.rule}
+	p.Gen, p.X, p.Y = u.Generation(), x, y
+	return WritePattern(name, p, defaultLiveBlock, defaultDeadBlock)
+}
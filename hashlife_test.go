@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestHashLifeAdvance checks Universe.Advance, with one worker and with
+// several, against State.Step on a plane large enough that nothing reaches
+// its edges.
+func TestHashLifeAdvance(t *testing.T) {
+	const size, soup, gens = 256, 32, 100
+	for _, rs := range []string{"B3/S23", "B36/S23", "B3678/S34678"} {
+		rule, err := ParseRule(rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for seed := int64(1); seed <= 3; seed++ {
+			rng := rand.New(rand.NewSource(seed))
+			l := NewState(size, size)
+			l.a = NewBoard(size, size)
+			for y := 0; y < soup; y++ {
+				for x := 0; x < soup; x++ {
+					l.a.Set((size-soup)/2+x, (size-soup)/2+y, rng.Intn(2) == 0)
+				}
+			}
+			l.SetRule(rule)
+			l.SetTopology(Plane)
+			start := l.a.Copy()
+			for i := 0; i < gens; i++ {
+				l.Step()
+			}
+
+			for _, workers := range []int{1, 4} {
+				u, err := NewUniverse(rule, workers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := u.Load(start, 0, 0); err != nil {
+					t.Fatal(err)
+				}
+				// Uneven counts exercise several powers of two.
+				for _, n := range []int64{1, 6, 29, 64} {
+					if err := u.Advance(n); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if !u.Board(0, 0, size, size).Equal(l.a) {
+					t.Errorf("rule %s, seed %d, %d workers: board differs from State after %d generations", rs, seed, workers, gens)
+				}
+				if got, want := u.Population(), int64(population(l.a)); got != want {
+					t.Errorf("rule %s, seed %d, %d workers: population %d, want %d", rs, seed, workers, got, want)
+				}
+			}
+		}
+	}
+}
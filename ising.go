@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// IsingParams are the parameters of the Ising model.
+type IsingParams struct {
+	J        float64 // coupling between neighboring spins
+	H        float64 // external field
+	T        float64 // temperature, in units where Boltzmann's constant is 1
+	HeatBath bool    // use heat-bath updates instead of Metropolis
+}
+
+// Ising is the two-dimensional Ising model on a torus. Spins are stored in
+// a Grid as 1 for up and 0 for down. Each Step is one sweep of the lattice in
+// checkerboard order: all the black sites, then all the white ones. Sites of
+// one color share no neighbors, so each half-sweep is split into horizontal
+// bands updated concurrently. Every row has its own random source, so the
+// result depends on the seed but not on the number of bands.
+type Ising struct {
+	s       *Grid
+	p       IsingParams
+	rngs    []*rand.Rand // one per row
+	workers int          // bands per half-sweep
+}
+
+// NewIsing returns a lattice of the given size, which must be even in both
+// directions, with spins drawn at random from r. The lattice is updated in
+// bands on up to workers goroutines.
+func NewIsing(w, h int, p IsingParams, workers int, r *rand.Rand) (*Ising, error) {
+	if w%2 != 0 || h%2 != 0 {
+		return nil, errors.New("the Ising lattice needs an even width and height")
+	}
+	if workers > h {
+		workers = h
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	m := &Ising{s: NewGrid(w, h), p: p, workers: workers}
+	m.s.Randomize(2, r)
+	for y := 0; y < h; y++ {
+		m.rngs = append(m.rngs, rand.New(rand.NewSource(r.Int63())))
+	}
+	return m, nil
+}
+
+// SetTemperature changes the temperature of the heat bath.
+func (m *Ising) SetTemperature(t float64) {
+	m.p.T = t
+}
+
+// spin returns the spin of the specified cell as ±1.
+func (m *Ising) spin(x, y int) float64 {
+	return float64(2*int(m.s.Get(x, y)) - 1)
+}
+
+// field returns the local field J·Σneighbors + H acting on the specified cell.
+func (m *Ising) field(x, y int) float64 {
+	sum := m.spin(x-1, y) + m.spin(x+1, y) + m.spin(x, y-1) + m.spin(x, y+1)
+	return m.p.J*sum + m.p.H
+}
+
+// update performs a single-site update of the specified cell.
+func (m *Ising) update(x, y int, r *rand.Rand) {
+	f := m.field(x, y)
+	if m.p.HeatBath {
+		// Choose the new spin from its conditional distribution.
+		up := 1 / (1 + math.Exp(-2*f/m.p.T))
+		v := uint8(0)
+		if r.Float64() < up {
+			v = 1
+		}
+		m.s.Set(x, y, v)
+		return
+	}
+	// Flip with probability min(1, exp(-ΔE/T)).
+	dE := 2 * m.spin(x, y) * f
+	if dE <= 0 || r.Float64() < math.Exp(-dE/m.p.T) {
+		m.s.Set(x, y, 1-m.s.Get(x, y))
+	}
+}
+
+// halfSweep updates every site whose coordinates sum to the given parity.
+func (m *Ising) halfSweep(parity int) {
+	var wg sync.WaitGroup
+	n := m.workers
+	for i := 0; i < n; i++ {
+		y0, y1 := i*m.s.h/n, (i+1)*m.s.h/n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := y0; y < y1; y++ {
+				r := m.rngs[y]
+				for x := (y + parity) % 2; x < m.s.w; x += 2 {
+					m.update(x, y, r)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Step performs one checkerboard sweep of the lattice.
+func (m *Ising) Step() {
+	m.halfSweep(0)
+	m.halfSweep(1)
+}
+
+// Magnetization returns the mean spin per site.
+func (m *Ising) Magnetization() float64 {
+	sum := 0.0
+	for y := 0; y < m.s.h; y++ {
+		for x := 0; x < m.s.w; x++ {
+			sum += m.spin(x, y)
+		}
+	}
+	return sum / float64(m.s.w*m.s.h)
+}
+
+// Energy returns the energy per site, counting each bond once.
+func (m *Ising) Energy() float64 {
+	e := 0.0
+	for y := 0; y < m.s.h; y++ {
+		for x := 0; x < m.s.w; x++ {
+			s := m.spin(x, y)
+			e -= m.p.J*s*(m.spin(x+1, y)+m.spin(x, y+1)) + m.p.H*s
+		}
+	}
+	return e / float64(m.s.w*m.s.h)
+}
+
+// IsingMeasurement holds thermal averages at a single temperature.
+type IsingMeasurement struct {
+	T              float64
+	Magnetization  float64 // ⟨|m|⟩ per site
+	Energy         float64 // ⟨e⟩ per site
+	Susceptibility float64 // N(⟨m²⟩ − ⟨|m|⟩²)/T
+	SpecificHeat   float64 // N(⟨e²⟩ − ⟨e⟩²)/T²
+}
+
+// Measure runs equil sweeps to equilibrate the lattice at its current
+// temperature and then averages the observables over samples sweeps,
+// which must be positive.
+func (m *Ising) Measure(equil, samples int) (IsingMeasurement, error) {
+	if samples < 1 {
+		return IsingMeasurement{}, errors.New("ising: at least one sample is needed")
+	}
+	for i := 0; i < equil; i++ {
+		m.Step()
+	}
+	var sm, sm2, se, se2 float64
+	for i := 0; i < samples; i++ {
+		m.Step()
+		mag := math.Abs(m.Magnetization())
+		e := m.Energy()
+		sm += mag
+		sm2 += mag * mag
+		se += e
+		se2 += e * e
+	}
+	n := float64(samples)
+	sites := float64(m.s.w * m.s.h)
+	sm, sm2, se, se2 = sm/n, sm2/n, se/n, se2/n
+	t := m.p.T
+	return IsingMeasurement{
+		T:              t,
+		Magnetization:  sm,
+		Energy:         se,
+		Susceptibility: sites * (sm2 - sm*sm) / t,
+		SpecificHeat:   sites * (se2 - se*se) / (t * t),
+	}, nil
+}
+
+var isingPalette = []color.RGBA{
+	{20, 30, 80, 255},
+	{240, 240, 240, 255},
+}
+
+// String returns the lattice with up spins drawn light and down spins dark.
+func (m *Ising) String() string {
+	return ansiGrid(m.s, isingPalette)
+}
+
+func runIsing(args []string) error {
+	var (
+		sf             simFlags
+		p              IsingParams
+		workers        int
+		tmin, tmax     float64
+		points         int
+		equil, samples int
+	)
+	fs := flag.NewFlagSet("ising", flag.ExitOnError)
+	sf.register(fs)
+	fs.Float64Var(&p.J, "J", 1, "coupling between neighbors")
+	fs.Float64Var(&p.H, "field", 0, "external field")
+	fs.Float64Var(&p.T, "T", 2.269, "temperature")
+	fs.BoolVar(&p.HeatBath, "heatbath", false, "use heat-bath instead of Metropolis updates")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "goroutines per half-sweep")
+	fs.Float64Var(&tmin, "tmin", 1.5, "lowest temperature of the sweep")
+	fs.Float64Var(&tmax, "tmax", 3.5, "highest temperature of the sweep")
+	fs.IntVar(&points, "sweep", 0, "measure at this many temperatures instead of drawing the lattice")
+	fs.IntVar(&equil, "equil", 1000, "equilibration sweeps per temperature")
+	fs.IntVar(&samples, "samples", 2000, "measurement sweeps per temperature")
+	fs.Parse(args)
+	if p.T <= 0 || points > 0 && (tmin <= 0 || tmax < tmin) {
+		return errors.New("temperatures must be positive, with tmin ≤ tmax")
+	}
+	if points > 0 && (samples < 1 || equil < 0) {
+		return errors.New("samples must be positive and equil not negative")
+	}
+	m, err := NewIsing(sf.w, sf.h, p, workers, sf.rand())
+	if err != nil {
+		return err
+	}
+	if points == 0 {
+		animate(m, sf.n, sf.delay)
+		return nil
+	}
+	// Sweep downwards from the disordered phase so each temperature starts
+	// near equilibrium.
+	cw := csv.NewWriter(os.Stdout)
+	cw.Write([]string{"T", "magnetization", "energy", "susceptibility", "specific_heat"})
+	for i := points - 1; i >= 0; i-- {
+		t := tmax
+		if points > 1 {
+			t = tmin + (tmax-tmin)*float64(i)/float64(points-1)
+		}
+		m.SetTemperature(t)
+		r, err := m.Measure(equil, samples)
+		if err != nil {
+			return err
+		}
+		row := []string{}
+		for _, v := range []float64{r.T, r.Magnetization, r.Energy, r.Susceptibility, r.SpecificHeat} {
+			row = append(row, strconv.FormatFloat(v, 'g', 6, 64))
+		}
+		cw.Write(row)
+		cw.Flush()
+	}
+	return cw.Error()
+}
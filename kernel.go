@@ -0,0 +1,73 @@
+package main
+
+// rowTable is a rule in the form the row kernels use. For each neighbor
+// count that leads to a live cell, an entry holds the four bits of the
+// count, then a birth mask and a survive mask, each spread to a whole word
+// of zeros or ones so they can be compared with 64 lanes at once.
+type rowTable struct {
+	n int
+	e [9][6]uint64
+}
+
+// newRowTable returns the table for r.
+func newRowTable(r Rule) *rowTable {
+	t := new(rowTable)
+	for c := 0; c <= 8; c++ {
+		birth, survive := r.Birth>>c&1, r.Survive>>c&1
+		if birth == 0 && survive == 0 {
+			continue
+		}
+		t.e[t.n] = [6]uint64{
+			-uint64(c & 1), -uint64(c >> 1 & 1), -uint64(c >> 2 & 1), -uint64(c >> 3 & 1),
+			-uint64(birth), -uint64(survive),
+		}
+		t.n++
+	}
+	return t
+}
+
+// stepWord returns the next word of the cell c given the words of its
+// eight neighbors: u above, d below, l and r beside it.
+func stepWord(ul, u, ur, l, c, r, dl, d, dr uint64, t *rowTable) uint64 {
+	// Sum the eight neighbors into a four-bit count c3c2c1c0 in each lane.
+	s0, k0 := fullAdd(ul, u, ur)
+	s1, k1 := fullAdd(dl, d, dr)
+	s2, k2 := l^r, l&r
+	c0, k3 := fullAdd(s0, s1, s2)
+	x, d0 := fullAdd(k0, k1, k2)
+	c1, d1 := x^k3, x&k3
+	c2, c3 := d0^d1, d0&d1
+
+	var next uint64
+	for _, e := range t.e[:t.n] {
+		diff := (c0 ^ e[0]) | (c1 ^ e[1]) | (c2 ^ e[2]) | (c3 ^ e[3])
+		next |= ^diff & (^c&e[4] | c&e[5])
+	}
+	return next
+}
+
+// A row kernel sets dst[i] for 0 < i < len(dst)-1 to the next word of
+// row[i], whose neighbors are at i-1, i and i+1 in up, row and down. The
+// first and last words, whose neighbors wrap around, are left to the caller.
+type rowKernel func(dst, up, row, down []uint64, t *rowTable)
+
+// stepRowGo is the portable row kernel.
+func stepRowGo(dst, up, row, down []uint64, t *rowTable) {
+	for i := 1; i < len(dst)-1; i++ {
+		dst[i] = stepWord(up[i-1], up[i], up[i+1],
+			row[i-1], row[i], row[i+1],
+			down[i-1], down[i], down[i+1], t)
+	}
+}
+
+// rowKernels lists the row kernels this CPU can run, the portable one
+// first. The architecture files add the assembly kernels the CPU supports.
+var rowKernels = []namedKernel{{"go", stepRowGo}}
+
+type namedKernel struct {
+	name string
+	step rowKernel
+}
+
+// stepRow is the fastest row kernel in rowKernels.
+var stepRow rowKernel = stepRowGo
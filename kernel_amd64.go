@@ -0,0 +1,48 @@
+//go:build !purego
+
+package main
+
+// stepRowAVX2Asm runs the row kernel on n words starting at dst, four at a
+// time. up, row and down point at the words level with dst, and n is a
+// multiple of four.
+//
+//go:noescape
+func stepRowAVX2Asm(dst, up, row, down *uint64, n int, t *rowTable)
+
+func cpuid(eax, ecx uint32) (a, b, c, d uint32)
+func xgetbv() (eax, edx uint32)
+
+// hasAVX2 reports whether both the CPU and the operating system support
+// AVX2: the OS must save the YMM registers on a context switch.
+func hasAVX2() bool {
+	if leaf, _, _, _ := cpuid(0, 0); leaf < 7 {
+		return false
+	}
+	_, _, c, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if c&osxsave == 0 || c&avx == 0 {
+		return false
+	}
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 { // XMM and YMM state
+		return false
+	}
+	_, b, _, _ := cpuid(7, 0)
+	return b&(1<<5) != 0
+}
+
+// stepRowAVX2 is the row kernel using AVX2, with the words left over
+// after the last group of four done in Go.
+func stepRowAVX2(dst, up, row, down []uint64, t *rowTable) {
+	n := max(len(dst)-2, 0) &^ 3
+	if n > 0 {
+		stepRowAVX2Asm(&dst[1], &up[1], &row[1], &down[1], n, t)
+	}
+	stepRowGo(dst[n:], up[n:], row[n:], down[n:], t)
+}
+
+func init() {
+	if hasAVX2() {
+		rowKernels = append(rowKernels, namedKernel{"avx2", stepRowAVX2})
+		stepRow = stepRowAVX2
+	}
+}
@@ -0,0 +1,129 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func stepRowAVX2Asm(dst, up, row, down *uint64, n int, t *rowTable)
+TEXT ·stepRowAVX2Asm(SB), NOSPLIT, $0-48
+	MOVQ dst+0(FP), DI
+	MOVQ up+8(FP), AX
+	MOVQ row+16(FP), BX
+	MOVQ down+24(FP), DX
+	MOVQ n+32(FP), CX
+	MOVQ t+40(FP), SI
+	MOVQ 0(SI), R9 // number of entries
+	ADDQ $8, SI    // first entry
+	TESTQ CX, CX
+	JZ   done
+
+loop:
+	// s0, k0 = fullAdd(up-left, up, up-right)
+	VMOVDQU -8(AX), Y0
+	VMOVDQU (AX), Y1
+	VMOVDQU 8(AX), Y2
+	VPXOR   Y1, Y0, Y3
+	VPAND   Y1, Y0, Y4
+	VPAND   Y2, Y3, Y5
+	VPOR    Y5, Y4, Y4
+	VPXOR   Y2, Y3, Y3
+
+	// s1, k1 = fullAdd(down-left, down, down-right)
+	VMOVDQU -8(DX), Y0
+	VMOVDQU (DX), Y1
+	VMOVDQU 8(DX), Y2
+	VPXOR   Y1, Y0, Y5
+	VPAND   Y1, Y0, Y6
+	VPAND   Y2, Y5, Y7
+	VPOR    Y7, Y6, Y6
+	VPXOR   Y2, Y5, Y5
+
+	// s2, k2 = half sum of left and right; Y1 holds the cells themselves.
+	VMOVDQU -8(BX), Y0
+	VMOVDQU (BX), Y1
+	VMOVDQU 8(BX), Y2
+	VPXOR   Y2, Y0, Y7
+	VPAND   Y2, Y0, Y8
+
+	// c0, k3 = fullAdd(s0, s1, s2)
+	VPXOR Y5, Y3, Y9
+	VPAND Y5, Y3, Y10
+	VPAND Y7, Y9, Y11
+	VPOR  Y11, Y10, Y10
+	VPXOR Y7, Y9, Y3
+
+	// t, d0 = fullAdd(k0, k1, k2)
+	VPXOR Y6, Y4, Y9
+	VPAND Y6, Y4, Y11
+	VPAND Y8, Y9, Y12
+	VPOR  Y12, Y11, Y11
+	VPXOR Y8, Y9, Y9
+
+	// c1, d1 = t+k3; c2, c3 = d0+d1. The count is now in Y8:Y7:Y5:Y3.
+	VPXOR Y10, Y9, Y5
+	VPAND Y10, Y9, Y6
+	VPXOR Y6, Y11, Y7
+	VPAND Y6, Y11, Y8
+
+	VPXOR Y0, Y0, Y0
+	MOVQ  SI, R8
+	MOVQ  R9, R10
+	TESTQ R10, R10
+	JZ    store
+
+entry:
+	// diff is zero in the lanes whose count matches the entry.
+	VPBROADCASTQ 0(R8), Y9
+	VPXOR        Y3, Y9, Y9
+	VPBROADCASTQ 8(R8), Y10
+	VPXOR        Y5, Y10, Y10
+	VPOR         Y10, Y9, Y9
+	VPBROADCASTQ 16(R8), Y10
+	VPXOR        Y7, Y10, Y10
+	VPOR         Y10, Y9, Y9
+	VPBROADCASTQ 24(R8), Y10
+	VPXOR        Y8, Y10, Y10
+	VPOR         Y10, Y9, Y9
+
+	// next |= ^diff & (^cell&birth | cell&survive)
+	VPBROADCASTQ 32(R8), Y10
+	VPANDN       Y10, Y1, Y10
+	VPBROADCASTQ 40(R8), Y11
+	VPAND        Y1, Y11, Y11
+	VPOR         Y11, Y10, Y10
+	VPANDN       Y10, Y9, Y10
+	VPOR         Y10, Y0, Y0
+
+	ADDQ $48, R8
+	DECQ R10
+	JNZ  entry
+
+store:
+	VMOVDQU Y0, (DI)
+	ADDQ    $32, AX
+	ADDQ    $32, BX
+	ADDQ    $32, DX
+	ADDQ    $32, DI
+	SUBQ    $4, CX
+	JNZ     loop
+
+done:
+	VZEROUPPER
+	RET
+
+// func cpuid(eax, ecx uint32) (a, b, c, d uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eax+0(FP), AX
+	MOVL ecx+4(FP), CX
+	CPUID
+	MOVL AX, a+8(FP)
+	MOVL BX, b+12(FP)
+	MOVL CX, c+16(FP)
+	MOVL DX, d+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
@@ -0,0 +1,26 @@
+//go:build !purego
+
+package main
+
+// stepRowNEONAsm runs the row kernel on n words starting at dst, two at a
+// time. up, row and down point at the words level with dst, and n is a
+// multiple of two.
+//
+//go:noescape
+func stepRowNEONAsm(dst, up, row, down *uint64, n int, t *rowTable)
+
+// stepRowNEON is the row kernel using NEON, with any odd word left over
+// done in Go.
+func stepRowNEON(dst, up, row, down []uint64, t *rowTable) {
+	n := max(len(dst)-2, 0) &^ 1
+	if n > 0 {
+		stepRowNEONAsm(&dst[1], &up[1], &row[1], &down[1], n, t)
+	}
+	stepRowGo(dst[n:], up[n:], row[n:], down[n:], t)
+}
+
+// NEON is a required part of ARMv8-A, so unlike AVX2 it needs no check.
+func init() {
+	rowKernels = append(rowKernels, namedKernel{"neon", stepRowNEON})
+	stepRow = stepRowNEON
+}
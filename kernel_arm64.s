@@ -0,0 +1,110 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func stepRowNEONAsm(dst, up, row, down *uint64, n int, t *rowTable)
+TEXT ·stepRowNEONAsm(SB), NOSPLIT, $0-48
+	MOVD dst+0(FP), R0
+	MOVD up+8(FP), R1
+	MOVD row+16(FP), R2
+	MOVD down+24(FP), R3
+	MOVD n+32(FP), R4
+	MOVD t+40(FP), R5
+	MOVD (R5), R6  // number of entries
+	ADD  $8, R5    // first entry
+	CBZ  R4, done
+
+	// Loads cannot take an offset, so keep a pointer to each neighbor.
+	SUB $8, R1, R7
+	ADD $8, R1, R8
+	SUB $8, R2, R9
+	ADD $8, R2, R10
+	SUB $8, R3, R11
+	ADD $8, R3, R12
+
+loop:
+	// s0, k0 = fullAdd(up-left, up, up-right)
+	VLD1.P 16(R7), [V0.D2]
+	VLD1.P 16(R1), [V1.D2]
+	VLD1.P 16(R8), [V2.D2]
+	VEOR   V1.B16, V0.B16, V3.B16
+	VAND   V1.B16, V0.B16, V4.B16
+	VAND   V2.B16, V3.B16, V5.B16
+	VORR   V5.B16, V4.B16, V4.B16
+	VEOR   V2.B16, V3.B16, V3.B16
+
+	// s1, k1 = fullAdd(down-left, down, down-right)
+	VLD1.P 16(R11), [V0.D2]
+	VLD1.P 16(R3), [V1.D2]
+	VLD1.P 16(R12), [V2.D2]
+	VEOR   V1.B16, V0.B16, V5.B16
+	VAND   V1.B16, V0.B16, V6.B16
+	VAND   V2.B16, V5.B16, V7.B16
+	VORR   V7.B16, V6.B16, V6.B16
+	VEOR   V2.B16, V5.B16, V5.B16
+
+	// s2, k2 = half sum of left and right; V1 holds the cells themselves.
+	VLD1.P 16(R9), [V0.D2]
+	VLD1.P 16(R2), [V1.D2]
+	VLD1.P 16(R10), [V2.D2]
+	VEOR   V2.B16, V0.B16, V7.B16
+	VAND   V2.B16, V0.B16, V8.B16
+
+	// c0, k3 = fullAdd(s0, s1, s2)
+	VEOR V5.B16, V3.B16, V9.B16
+	VAND V5.B16, V3.B16, V10.B16
+	VAND V7.B16, V9.B16, V11.B16
+	VORR V11.B16, V10.B16, V10.B16
+	VEOR V7.B16, V9.B16, V3.B16
+
+	// t, d0 = fullAdd(k0, k1, k2)
+	VEOR V6.B16, V4.B16, V9.B16
+	VAND V6.B16, V4.B16, V11.B16
+	VAND V8.B16, V9.B16, V12.B16
+	VORR V12.B16, V11.B16, V11.B16
+	VEOR V8.B16, V9.B16, V9.B16
+
+	// c1, d1 = t+k3; c2, c3 = d0+d1. The count is now in V8:V7:V5:V3.
+	VEOR V10.B16, V9.B16, V5.B16
+	VAND V10.B16, V9.B16, V6.B16
+	VEOR V6.B16, V11.B16, V7.B16
+	VAND V6.B16, V11.B16, V8.B16
+
+	VEOR V0.B16, V0.B16, V0.B16
+	MOVD R5, R13
+	MOVD R6, R14
+	CBZ  R14, store
+
+entry:
+	// diff is zero in the lanes whose count matches the entry.
+	VLD1R.P 8(R13), [V9.D2]
+	VEOR    V3.B16, V9.B16, V9.B16
+	VLD1R.P 8(R13), [V10.D2]
+	VEOR    V5.B16, V10.B16, V10.B16
+	VORR    V10.B16, V9.B16, V9.B16
+	VLD1R.P 8(R13), [V10.D2]
+	VEOR    V7.B16, V10.B16, V10.B16
+	VORR    V10.B16, V9.B16, V9.B16
+	VLD1R.P 8(R13), [V10.D2]
+	VEOR    V8.B16, V10.B16, V10.B16
+	VORR    V10.B16, V9.B16, V9.B16
+
+	// next |= ^diff & (^cell&birth | cell&survive)
+	VLD1R.P 8(R13), [V10.D2]
+	VBIC    V1.B16, V10.B16, V10.B16
+	VLD1R.P 8(R13), [V11.D2]
+	VAND    V1.B16, V11.B16, V11.B16
+	VORR    V11.B16, V10.B16, V10.B16
+	VBIC    V9.B16, V10.B16, V10.B16
+	VORR    V10.B16, V0.B16, V0.B16
+
+	SUB  $1, R14
+	CBNZ R14, entry
+
+store:
+	VST1.P [V0.D2], 16(R0)
+	SUBS   $2, R4
+	BNE    loop
+
+done:
+	RET
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestRowKernels checks every row kernel this CPU runs, the portable one
+// included, by stepping whole boards with it against State.Step, and then
+// the assembly kernels against stepRowGo on rows of every length up to a
+// few vector widths and some odd ones past them, so that the assembly's
+// leftover handling is covered.
+//
+// Only the kernels the CPU running the test supports are checked. On amd64
+// without AVX2 that is just the portable one. The NEON kernel is built by
+// GOARCH=arm64 go vet and go build but only checked when the tests are run
+// on arm64, natively or under an emulator such as qemu-aarch64.
+func TestRowKernels(t *testing.T) {
+	if len(rowKernels) == 1 {
+		t.Log("no assembly row kernel in this build or on this CPU; checking only the portable one")
+	}
+	for _, k := range rowKernels {
+		checkBitBoards(t, k.name, k.step)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	words := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 17, 31, 33, 63, 65, 67}
+	for _, k := range rowKernels[1:] {
+		for _, rs := range []string{"B3/S23", "B36/S23", "B3678/S34678", "B2/S", "B1357/S1357", "B/S012345678"} {
+			rule, err := ParseRule(rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tab := newRowTable(rule)
+			for _, n := range words {
+				for trial := 0; trial < 20; trial++ {
+					up, row, down := randomWords(n, rng), randomWords(n, rng), randomWords(n, rng)
+					want, got := randomWords(n, rng), make([]uint64, n)
+					copy(got, want)
+					stepRowGo(want, up, row, down, tab)
+					k.step(got, up, row, down, tab)
+					for i := range want {
+						if got[i] != want[i] {
+							t.Fatalf("%s kernel, rule %s, %d words: word %d is %#x, want %#x",
+								k.name, rs, n, i, got[i], want[i])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func randomWords(n int, rng *rand.Rand) []uint64 {
+	w := make([]uint64, n)
+	for i := range w {
+		w[i] = rng.Uint64()
+	}
+	return w
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// LatticeGas is a lattice gas automaton: HPP on a square lattice or FHP on a
+// hexagonal one. Each cell of the underlying Grid packs one bit per
+// direction, set when a particle is moving that way. A step is a collision
+// phase, which rearranges the particles within each cell while conserving
+// mass and momentum, followed by a streaming phase, which moves every
+// particle to the neighboring cell it is heading for.
+//
+// Directions are numbered counterclockwise from east. The hexagonal lattice
+// is stored with odd rows shifted half a cell to the right.
+type LatticeGas struct {
+	a, b     *Grid
+	obstacle *Board // cells that reflect particles back where they came from
+	dirs     int    // 4 for HPP, 6 for FHP
+	collide  [2][]uint8
+	r        *rand.Rand
+}
+
+// NewHPP returns an empty HPP gas on a square lattice of the given size.
+func NewHPP(w, h int, r *rand.Rand) *LatticeGas {
+	g := newLatticeGas(w, h, 4, r)
+	// A head-on pair leaves at right angles; everything else passes through.
+	g.collide[0][0x5], g.collide[0][0xa] = 0xa, 0x5
+	g.collide[1] = g.collide[0]
+	return g
+}
+
+// NewFHP returns an empty FHP gas on a hexagonal lattice of the given size.
+// The height must be even so that the row offsets line up across the wrap.
+func NewFHP(w, h int, r *rand.Rand) *LatticeGas {
+	g := newLatticeGas(w, h, 6, r)
+	// A head-on pair rotates by 60° one way or the other, chosen at random;
+	// a symmetric triple rotates by 60°.
+	for d := 0; d < 3; d++ {
+		pair := uint8(1<<d | 1<<(d+3))
+		g.collide[0][pair] = g.rotate(pair, 1)
+		g.collide[1][pair] = g.rotate(pair, 5)
+	}
+	for c := 0; c < 2; c++ {
+		g.collide[c][0x15], g.collide[c][0x2a] = 0x2a, 0x15
+	}
+	return g
+}
+
+func newLatticeGas(w, h, dirs int, r *rand.Rand) *LatticeGas {
+	g := &LatticeGas{
+		a: NewGrid(w, h), b: NewGrid(w, h),
+		obstacle: NewBoard(w, h),
+		dirs:     dirs,
+		r:        r,
+	}
+	for c := range g.collide {
+		g.collide[c] = make([]uint8, 1<<dirs)
+		for s := range g.collide[c] {
+			g.collide[c][s] = uint8(s)
+		}
+	}
+	return g
+}
+
+// rotate returns the particle set s turned by n directions counterclockwise.
+func (g *LatticeGas) rotate(s uint8, n int) uint8 {
+	mask := uint8(1<<g.dirs - 1)
+	return (s<<n | s>>(g.dirs-n)) & mask
+}
+
+// Fill adds a particle to each direction of each open cell with probability
+// density. An extra particle moving east is added with probability drift,
+// giving the gas a net flow.
+func (g *LatticeGas) Fill(density, drift float64) {
+	for y := 0; y < g.a.h; y++ {
+		for x := 0; x < g.a.w; x++ {
+			if g.obstacle.Active(x, y) {
+				continue
+			}
+			var s uint8
+			for d := 0; d < g.dirs; d++ {
+				if g.r.Float64() < density {
+					s |= 1 << d
+				}
+			}
+			if g.r.Float64() < drift {
+				s |= 1
+			}
+			g.a.Set(x, y, s)
+		}
+	}
+}
+
+// SetObstacle marks the specified cell as solid, removing any particles in it.
+func (g *LatticeGas) SetObstacle(x, y int) {
+	g.obstacle.Set(x, y, true)
+	g.a.Set(x, y, 0)
+}
+
+// neighbor returns the coordinates of the cell adjacent to (x, y) in
+// direction d. The result may lie outside the field; Grid.Get wraps it.
+func (g *LatticeGas) neighbor(x, y, d int) (int, int) {
+	if g.dirs == 4 {
+		switch d {
+		case 0:
+			return x + 1, y
+		case 1:
+			return x, y - 1
+		case 2:
+			return x - 1, y
+		default:
+			return x, y + 1
+		}
+	}
+	odd := y & 1
+	switch d {
+	case 0:
+		return x + 1, y
+	case 1:
+		return x + odd, y - 1
+	case 2:
+		return x - 1 + odd, y - 1
+	case 3:
+		return x - 1, y
+	case 4:
+		return x - 1 + odd, y + 1
+	default:
+		return x + odd, y + 1
+	}
+}
+
+// velocity returns the unit velocity of a particle moving in direction d,
+// with y increasing downwards as on screen.
+func (g *LatticeGas) velocity(d int) (vx, vy float64) {
+	a := 2 * math.Pi * float64(d) / float64(g.dirs)
+	return math.Cos(a), -math.Sin(a)
+}
+
+// Step advances the gas by one collision and streaming phase.
+func (g *LatticeGas) Step() {
+	half := g.dirs / 2
+	for y := 0; y < g.a.h; y++ {
+		for x := 0; x < g.a.w; x++ {
+			s := g.a.Get(x, y)
+			if g.obstacle.Active(x, y) {
+				s = g.rotate(s, half) // Bounce back.
+			} else {
+				s = g.collide[g.r.Intn(2)][s]
+			}
+			g.a.Set(x, y, s)
+		}
+	}
+	// Each particle arriving at (x, y) in direction d comes from the
+	// neighbor in the opposite direction.
+	for y := 0; y < g.a.h; y++ {
+		for x := 0; x < g.a.w; x++ {
+			var s uint8
+			for d := 0; d < g.dirs; d++ {
+				nx, ny := g.neighbor(x, y, (d+half)%g.dirs)
+				s |= g.a.Get(nx, ny) & (1 << d)
+			}
+			g.b.Set(x, y, s)
+		}
+	}
+	g.a, g.b = g.b, g.a
+}
+
+// Particles returns the total number of particles in the gas.
+func (g *LatticeGas) Particles() int {
+	n := 0
+	for y := 0; y < g.a.h; y++ {
+		for x := 0; x < g.a.w; x++ {
+			n += bits.OnesCount8(g.a.Get(x, y))
+		}
+	}
+	return n
+}
+
+// String returns the field shaded by the number of particles in each cell,
+// with obstacles drawn as '#'.
+func (g *LatticeGas) String() string {
+	const shades = " .:-=+*%"
+	var buf bytes.Buffer
+	for y := 0; y < g.a.h; y++ {
+		for x := 0; x < g.a.w; x++ {
+			b := shades[bits.OnesCount8(g.a.Get(x, y))]
+			if g.obstacle.Active(x, y) {
+				b = '#'
+			}
+			buf.WriteByte(b)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// WriteVelocity writes the velocity field coarse-grained over block×block
+// tiles to w as CSV. Each row gives the tile's origin, its mean number of
+// particles per cell and its mean particle velocity.
+func (g *LatticeGas) WriteVelocity(w io.Writer, block int) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"x", "y", "density", "ux", "uy"})
+	for by := 0; by < g.a.h; by += block {
+		for bx := 0; bx < g.a.w; bx += block {
+			var n, cells int
+			var px, py float64
+			for y := by; y < by+block && y < g.a.h; y++ {
+				for x := bx; x < bx+block && x < g.a.w; x++ {
+					cells++
+					s := g.a.Get(x, y)
+					for d := 0; d < g.dirs; d++ {
+						if s&(1<<d) != 0 {
+							vx, vy := g.velocity(d)
+							px += vx
+							py += vy
+							n++
+						}
+					}
+				}
+			}
+			var ux, uy float64
+			if n > 0 {
+				ux, uy = px/float64(n), py/float64(n)
+			}
+			cw.Write([]string{
+				strconv.Itoa(bx), strconv.Itoa(by),
+				strconv.FormatFloat(float64(n)/float64(cells), 'g', 6, 64),
+				strconv.FormatFloat(ux, 'g', 6, 64),
+				strconv.FormatFloat(uy, 'g', 6, 64),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runLattice(args []string) error {
+	var (
+		sf      simFlags
+		fhp     bool
+		density float64
+		drift   float64
+		radius  int
+		block   int
+		out     string
+	)
+	fs := flag.NewFlagSet("lattice", flag.ExitOnError)
+	sf.register(fs)
+	fs.BoolVar(&fhp, "fhp", false, "use the hexagonal FHP lattice instead of HPP")
+	fs.Float64Var(&density, "density", 0.2, "probability of a particle in each direction")
+	fs.Float64Var(&drift, "drift", 0, "probability of an extra eastbound particle per cell")
+	fs.IntVar(&radius, "obstacle", 0, "radius of a circular obstacle in the middle of the field")
+	fs.IntVar(&block, "block", 8, "tile size for the coarse-grained velocity field")
+	fs.StringVar(&out, "velocity", "", "write the final velocity field to this CSV file")
+	fs.Parse(args)
+	if block < 1 {
+		return errors.New("block must be positive")
+	}
+	if radius < 0 || 2*radius+1 > min(sf.w, sf.h) {
+		return fmt.Errorf("obstacle radius must be between 0 and %d on a %d×%d field, got %d",
+			(min(sf.w, sf.h)-1)/2, sf.w, sf.h, radius)
+	}
+	var g *LatticeGas
+	if fhp {
+		if sf.h%2 != 0 {
+			return errors.New("the FHP lattice needs an even height")
+		}
+		g = NewFHP(sf.w, sf.h, sf.rand())
+	} else {
+		g = NewHPP(sf.w, sf.h, sf.rand())
+	}
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if radius > 0 && x*x+y*y <= radius*radius {
+				g.SetObstacle((sf.w/2+x+sf.w)%sf.w, (sf.h/2+y+sf.h)%sf.h)
+			}
+		}
+	}
+	g.Fill(density, drift)
+	animate(g, sf.n, sf.delay)
+	if out == "" {
+		return nil
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := g.WriteVelocity(f, block); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
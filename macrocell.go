@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Macrocell returns the pattern in Golly's macrocell format: its quadtree
+// with each distinct node written once, level 3 nodes as 8×8 bitmaps.
+// Metadata is written as #C comment lines, with name and author as
+// "Key: value" lines, and the generation on a #G line. The root is centered
+// on the origin, which places the cells.
+func (p *Pattern) Macrocell() (string, error) {
+	u, err := NewUniverse(p.Rule, 1)
+	if err != nil {
+		return "", err
+	}
+	if err := u.LoadBig(p.Board, p.X, p.Y); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString("[M2] (gameoflife)\n")
+	fmt.Fprintf(&out, "#R %s\n", p.Rule)
+	if p.Gen.Sign() != 0 {
+		fmt.Fprintf(&out, "#G %s\n", p.Gen)
+	}
+	for _, c := range p.commentLines(true, false) {
+		fmt.Fprintf(&out, "#C %s\n", c)
+	}
+
+	// Nodes are numbered from 1 in the order written, children first; 0 is
+	// the empty node of any level.
+	ids := map[*node]int{}
+	var write func(n *node) int
+	write = func(n *node) int {
+		if n.pop == 0 {
+			return 0
+		}
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		if n.level == 3 {
+			var rows []string
+			for y := 0; y < 8; y++ {
+				row := make([]byte, 8)
+				for x := range row {
+					row[x] = ".*"[btoi(leafAt(n, x, y))]
+				}
+				rows = append(rows, strings.TrimRight(string(row), "."))
+			}
+			out.WriteString(strings.TrimRight(strings.Join(rows, "$"), "$") + "$\n")
+		} else {
+			nw, ne, sw, se := write(n.nw), write(n.ne), write(n.sw), write(n.se)
+			fmt.Fprintf(&out, "%d %d %d %d %d\n", n.level, nw, ne, sw, se)
+		}
+		ids[n] = len(ids) + 1
+		return ids[n]
+	}
+	write(u.root)
+	return out.String(), nil
+}
+
+// leafAt reports whether the cell at (x, y) within n is alive.
+func leafAt(n *node, x, y int) bool {
+	for n.level > 0 && n.pop > 0 {
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n.pop > 0
+}
+
+// ParseMacrocell parses a two-state pattern in Golly's macrocell format, on
+// a board just large enough to hold it. The rule is Conway's unless a #R
+// line names another; #C lines are read as comments and a #G line as the
+// generation. The root is taken to be centered on the origin.
+func ParseMacrocell(s string) (*Pattern, error) {
+	p := &Pattern{Rule: Conway}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "[M2]") {
+		return nil, errors.New("macrocell: missing [M2] header")
+	}
+	var t *nodeTable
+	nodes := []*node{nil} // by number; 0 is the empty node
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#R"):
+			r, err := ParseRule(strings.TrimSpace(line[2:]))
+			if err != nil {
+				return nil, fmt.Errorf("macrocell: %v", err)
+			}
+			p.Rule = r
+			continue
+		case strings.HasPrefix(line, "#G"):
+			g, err := ParseBigInt(line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("macrocell: %v", err)
+			}
+			p.Gen = g
+			continue
+		case strings.HasPrefix(line, "#"):
+			if len(line) > 1 && (line[1] == 'C' || line[1] == 'D') {
+				if err := p.parseComment(line[2:], &p.Rule); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if t == nil {
+			t = newNodeTable(p.Rule)
+		}
+		if c := line[0]; c == '.' || c == '*' || c == '$' {
+			var cells [8][8]bool
+			x, y := 0, 0
+			for _, c := range line {
+				if c != '$' && (x >= 8 || y >= 8) {
+					return nil, fmt.Errorf("macrocell: leaf %q is larger than 8×8", line)
+				}
+				switch c {
+				case '.':
+					x++
+				case '*':
+					cells[y][x] = true
+					x++
+				case '$':
+					x, y = 0, y+1
+				default:
+					return nil, fmt.Errorf("macrocell: unexpected %q in leaf", c)
+				}
+			}
+			nodes = append(nodes, t.bitmap(&cells, 0, 0, 3))
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 5 {
+			return nil, fmt.Errorf("macrocell: malformed node %q", line)
+		}
+		var v [5]int
+		for i := range f {
+			n, err := strconv.Atoi(f[i])
+			if err != nil || n < 0 || i > 0 && n >= len(nodes) {
+				return nil, fmt.Errorf("macrocell: malformed node %q", line)
+			}
+			v[i] = n
+		}
+		level := v[0]
+		if level < 4 || level > maxLevel {
+			return nil, fmt.Errorf("macrocell: node level %d out of range", level)
+		}
+		var q [4]*node
+		for i, id := range v[1:] {
+			q[i] = nodes[id]
+			if q[i] == nil {
+				q[i] = t.empty[level-1]
+			} else if int(q[i].level) != level-1 {
+				return nil, fmt.Errorf("macrocell: node %q has children of the wrong level", line)
+			}
+		}
+		nodes = append(nodes, t.join(q[0], q[1], q[2], q[3]))
+	}
+	if len(nodes) == 1 {
+		p.Board = NewBoard(0, 0)
+		return p, nil
+	}
+	root := nodes[len(nodes)-1]
+	half := pow2(uint(root.level) - 1)
+	u := &Universe{
+		h: hashlife{t: t}, root: root,
+		ox: NewBigInt(0).Sub(half), oy: NewBigInt(0).Sub(half),
+	}
+	x, y, w, h := u.BoundsBig()
+	if limit := NewBigInt(1 << 16); w.Cmp(limit) > 0 || h.Cmp(limit) > 0 || w.n*h.n > maxPatternArea {
+		return nil, fmt.Errorf("macrocell: pattern is too large for a board (%s×%s)", w.Display(), h.Display())
+	}
+	p.Board = u.BoardBig(x, y, int(w.n), int(h.n))
+	p.X, p.Y = x, y
+	return p, nil
+}
+
+// bitmap returns the node of the given level for the square of cells with
+// its top-left corner at (x, y).
+func (t *nodeTable) bitmap(cells *[8][8]bool, x, y int, level uint8) *node {
+	if level == 0 {
+		return t.leaf[btoi(cells[y][x])]
+	}
+	half := 1 << (level - 1)
+	return t.join(
+		t.bitmap(cells, x, y, level-1), t.bitmap(cells, x+half, y, level-1),
+		t.bitmap(cells, x, y+half, level-1), t.bitmap(cells, x+half, y+half, level-1),
+	)
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"strconv"
 	"time"
 )
 
@@ -38,6 +42,30 @@ func (f *Board) Active(x, y int) bool {
 	return f.s[y][x]
 }
 
+// Copy returns a copy of the field.
+func (f *Board) Copy() *Board {
+	c := NewBoard(f.w, f.h)
+	for y := range f.s {
+		copy(c.s[y], f.s[y])
+	}
+	return c
+}
+
+// Equal reports whether f and g have the same size and cells.
+func (f *Board) Equal(g *Board) bool {
+	if f.w != g.w || f.h != g.h {
+		return false
+	}
+	for y := range f.s {
+		for x := range f.s[y] {
+			if f.s[y][x] != g.s[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // Next returns the state of the specified cell at the next time step.
 func (f *Board) Next(x, y int) bool {
 	// Count the adjacent cells that are active.
@@ -60,30 +88,55 @@ func (f *Board) Next(x, y int) bool {
 type State struct {
 	a, b *Board
 	w, h int
+	rule Rule
+	topo Topology
+	gen  int // generations since the state was created
 }
 
 // NewState returns a new State game state with a random initial state.
 func NewState(w, h int) *State {
+	return NewStateRand(w, h, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// NewStateRand is like NewState but draws the initial state from r.
+func NewStateRand(w, h int, r *rand.Rand) *State {
 	a := NewBoard(w, h)
 	for i := 0; i < (w * h / 4); i++ {
-		a.Set(rand.Intn(w), rand.Intn(h), true)
+		a.Set(r.Intn(w), r.Intn(h), true)
 	}
 	return &State{
 		a: a, b: NewBoard(w, h),
 		w: w, h: h,
+		rule: Conway,
 	}
 }
 
+// SetRule changes the rule under which the game evolves.
+func (l *State) SetRule(r Rule) {
+	l.rule = r
+}
+
+// SetTopology changes how the edges of the board connect.
+func (l *State) SetTopology(t Topology) {
+	l.topo = t
+}
+
+// Generation returns the number of times the game has been stepped.
+func (l *State) Generation() int {
+	return l.gen
+}
+
 // Step advances the game by one instant, recomputing and updating all cells.
 func (l *State) Step() {
 	// Update the state of the next field (b) from the current field (a).
 	for y := 0; y < l.h; y++ {
 		for x := 0; x < l.w; x++ {
-			l.b.Set(x, y, l.a.Next(x, y))
+			l.b.Set(x, y, l.a.NextRule(x, y, l.rule, l.topo))
 		}
 	}
 	// Swap fields a and b.
 	l.a, l.b = l.b, l.a
+	l.gen++
 }
 
 // String returns the game board as a string.
@@ -102,11 +155,137 @@ func (l *State) String() string {
 	return buf.String()
 }
 
+// Model is a cellular automaton that can be advanced and printed.
+// State and the multi-state models all satisfy it.
+type Model interface {
+	Step()
+	String() string
+}
+
+// animate advances m n times, printing every generation.
+func animate(m Model, n int, delay time.Duration) {
+	for i := 0; i < n; i++ {
+		m.Step()
+		fmt.Print("\x0c", m) // Clear screen and print field.
+		time.Sleep(delay)
+	}
+}
+
+// skip is a Model that advances the wrapped Model n times per Step, for
+// models whose individual steps are too small to be worth printing.
+type skip struct {
+	Model
+	n int
+}
+
+func (s skip) Step() {
+	for i := 0; i < s.n; i++ {
+		s.Model.Step()
+	}
+}
+
+// simFlags holds the flags shared by the simulation commands.
+type simFlags struct {
+	w, h  int
+	n     int
+	seed  int64
+	delay time.Duration
+}
+
+// register adds the shared flags to fs. The board size must be positive
+// and the delay not negative, which the flags check as they are parsed.
+func (s *simFlags) register(fs *flag.FlagSet) {
+	s.w, s.h, s.delay = 40, 16, time.Second/30
+	fs.Var((*positiveInt)(&s.w), "w", "board `width`")
+	fs.Var((*positiveInt)(&s.h), "h", "board `height`")
+	fs.IntVar(&s.n, "n", 300, "number of generations")
+	fs.Int64Var(&s.seed, "seed", 0, "random seed (0 picks one from the clock)")
+	fs.Var((*nonNegativeDuration)(&s.delay), "delay", "delay between generations, as a `duration`")
+}
+
+// positiveInt is an int flag that must be at least 1.
+type positiveInt int
+
+func (v *positiveInt) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *positiveInt) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("parse error")
+	}
+	if n < 1 {
+		return errors.New("must be positive")
+	}
+	*v = positiveInt(n)
+	return nil
+}
+
+// nonNegativeDuration is a duration flag that must not be negative.
+type nonNegativeDuration time.Duration
+
+func (v *nonNegativeDuration) String() string { return time.Duration(*v).String() }
+
+func (v *nonNegativeDuration) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("parse error")
+	}
+	if d < 0 {
+		return errors.New("must not be negative")
+	}
+	*v = nonNegativeDuration(d)
+	return nil
+}
+
+// rand returns a random source for the configured seed.
+func (s *simFlags) rand() *rand.Rand {
+	if s.seed == 0 {
+		s.seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(s.seed))
+}
+
+// commands maps subcommand names to their implementations.
+// Each is called with the arguments following its name.
+var commands = map[string]func(args []string) error{
+	"align":    runAlign,
+	"attach":   runAttach,
+	"bench":    runBench,
+	"control":  runControl,
+	"convert":  runConvert,
+	"cyclic":   runCyclic,
+	"daemon":   runDaemon,
+	"fire":     runForestFire,
+	"gh":       runExcitable,
+	"gs":       runGrayScott,
+	"hashlife": runHashLife,
+	"ising":    runIsing,
+	"schem":    runSchematic,
+	"lattice":  runLattice,
+	"majority": runMajority,
+	"receive":  runReceiver,
+	"sandpile": runSandpile,
+	"serve":    runServe,
+	"sir":      runEpidemic,
+	"soup":     runSoup,
+	"sweep":    runSweep,
+	"torus":    runTorus,
+	"tui":      runTUI,
+}
+
 func main() {
-	l := NewState(40, 15)
-	for i := 0; i < 300; i++ {
-		l.Step()
-		fmt.Print("\x0c", l) // Clear screen and print field.
-		time.Sleep(time.Second / 30)
+	if len(os.Args) > 1 {
+		run, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		if err := run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
+	l := NewState(40, 15)
+	animate(l, 300, time.Second/30)
 }
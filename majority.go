@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule1D is a one-dimensional binary cellular automaton rule of radius 3:
+// bit i is the next state of a cell whose neighborhood, the seven cells
+// from three to its left to three to its right read as a binary number
+// with the leftmost cell most significant, is i.
+type Rule1D [2]uint64
+
+// bit returns the next state for neighborhood i.
+func (r *Rule1D) bit(i int) byte {
+	return byte(r[i>>6] >> (i & 63) & 1)
+}
+
+func (r *Rule1D) set(i int, v byte) {
+	r[i>>6] = r[i>>6]&^(1<<(i&63)) | uint64(v)<<(i&63)
+}
+
+// flip inverts the next state for neighborhood i.
+func (r *Rule1D) flip(i int) {
+	r[i>>6] ^= 1 << (i & 63)
+}
+
+// lambda returns the fraction of neighborhoods that lead to state 1.
+func (r Rule1D) lambda() float64 {
+	n := 0
+	for i := 0; i < 128; i++ {
+		n += int(r.bit(i))
+	}
+	return float64(n) / 128
+}
+
+// String returns the rule as 32 hexadecimal digits, the first giving the
+// next states of neighborhoods 0 to 3 with neighborhood 0 as its highest
+// bit, as rules are written in the literature on the density task.
+func (r Rule1D) String() string {
+	var s strings.Builder
+	for i := 0; i < 128; i += 4 {
+		d := r.bit(i)<<3 | r.bit(i+1)<<2 | r.bit(i+2)<<1 | r.bit(i+3)
+		s.WriteByte("0123456789ABCDEF"[d])
+	}
+	return s.String()
+}
+
+// ParseRule1D parses a rule written as by Rule1D.String, or the name gkl.
+func ParseRule1D(s string) (Rule1D, error) {
+	if strings.EqualFold(s, "gkl") {
+		return gklRule(), nil
+	}
+	var r Rule1D
+	if len(s) != 32 {
+		return r, fmt.Errorf("rule %q is not 32 hexadecimal digits", s)
+	}
+	for j, c := range s {
+		d, err := strconv.ParseUint(string(c), 16, 4)
+		if err != nil {
+			return r, fmt.Errorf("rule %q is not 32 hexadecimal digits", s)
+		}
+		for k := 0; k < 4; k++ {
+			r.set(4*j+k, byte(d>>(3-k)&1))
+		}
+	}
+	return r, nil
+}
+
+// gklRule returns the rule of Gacs, Kurdyumov and Levin, a human-designed
+// solution to the density task: a cell in state 0 takes the majority of
+// itself and its neighbors one and three cells to the left, and a cell in
+// state 1 the majority of itself and those to the right.
+func gklRule() Rule1D {
+	var r Rule1D
+	for i := 0; i < 128; i++ {
+		c := func(k int) int { return i >> (3 - k) & 1 } // cell at offset k
+		var sum int
+		if c(0) == 0 {
+			sum = c(0) + c(-1) + c(-3)
+		} else {
+			sum = c(0) + c(1) + c(3)
+		}
+		r.set(i, byte(btoi(sum >= 2)))
+	}
+	return r
+}
+
+// step computes the next configuration of the ring src into dst under r,
+// reporting whether any cell changed.
+func (r *Rule1D) step(dst, src []byte) (changed bool) {
+	n := len(src)
+	i := 0 // neighborhood of cell 0
+	for k := -3; k <= 3; k++ {
+		i = i<<1 | int(src[(k+n)%n])
+	}
+	for x := range src {
+		dst[x] = r.bit(i)
+		if dst[x] != src[x] {
+			changed = true
+		}
+		i = (i<<1 | int(src[(x+4)%n])) & 127
+	}
+	return changed
+}
+
+// densityTask is the density classification task: a rule solves an
+// initial configuration of an odd-sized ring if, within the given number
+// of steps, every cell takes the state held by the majority at the start.
+type densityTask struct {
+	steps   int
+	workers int
+}
+
+// classifies reports whether r solves the initial configuration ic. buf is
+// scratch space of twice its length.
+func (t densityTask) classifies(r *Rule1D, ic, buf []byte) bool {
+	n := len(ic)
+	a, b := buf[:n], buf[n:2*n]
+	copy(a, ic)
+	ones := 0
+	for _, c := range ic {
+		ones += int(c)
+	}
+	want := byte(btoi(2*ones > n))
+	for s := 0; s < t.steps; s++ {
+		if !r.step(b, a) {
+			break // a fixed point
+		}
+		a, b = b, a
+	}
+	for _, c := range a {
+		if c != want {
+			return false
+		}
+	}
+	return true
+}
+
+// score returns the fraction of the initial configurations each rule
+// solves, sharing the rules among the workers.
+func (t densityTask) score(rules []Rule1D, ics [][]byte) []float64 {
+	scores := make([]float64, len(rules))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(t.workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 2*len(ics[0]))
+			for i := range jobs {
+				solved := 0
+				for _, ic := range ics {
+					solved += btoi(t.classifies(&rules[i], ic, buf))
+				}
+				scores[i] = float64(solved) / float64(len(ics))
+			}
+		}()
+	}
+	for i := range rules {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return scores
+}
+
+// performance returns the fraction of count configurations of n cells,
+// each cell drawn independently with equal chances, that t solves under r.
+// Most such configurations have a density close to one half, which makes
+// this much harder than the fitness the rules are evolved under.
+func (t densityTask) performance(r Rule1D, n, count int, rng *rand.Rand) float64 {
+	ics := make([][]byte, count)
+	for i := range ics {
+		ics[i] = randomConfig(n, 0.5, rng)
+	}
+	// The configurations are shared among the workers by scoring one copy
+	// of the rule per worker on a slice of them each.
+	w := max(t.workers, 1)
+	chunk := (count + w - 1) / w
+	var solved float64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for lo := 0; lo < count; lo += chunk {
+		part := ics[lo:min(lo+chunk, count)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := densityTask{steps: t.steps, workers: 1}.score([]Rule1D{r}, part)[0]
+			mu.Lock()
+			solved += s * float64(len(part))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return solved / float64(count)
+}
+
+// randomConfig returns n cells, each 1 with chance p.
+func randomConfig(n int, p float64, rng *rand.Rand) []byte {
+	c := make([]byte, n)
+	for i := range c {
+		c[i] = byte(btoi(rng.Float64() < p))
+	}
+	return c
+}
+
+// evolveParams are the settings of the genetic algorithm, after Mitchell,
+// Crutchfield and Das's experiments evolving rules for the density task.
+type evolveParams struct {
+	n          int // ring size
+	pop, elite int // rules per generation, and how many survive unchanged
+	ics        int // initial configurations per generation
+	gens       int
+	mutations  int // bits flipped in each offspring
+}
+
+// generationLog is the fitness of one generation of the genetic algorithm.
+type generationLog struct {
+	gen        int
+	best, mean float64
+	rule       Rule1D // the fittest rule
+}
+
+// evolve runs the genetic algorithm and returns the fittest rule of the
+// last generation, calling report after each generation. Every random
+// choice is drawn from rng, so a seed reproduces a run.
+func evolve(p evolveParams, t densityTask, rng *rand.Rand, report func(generationLog) error) (Rule1D, error) {
+	// The first generation has rules with every fraction of 1s in their
+	// tables, rather than all close to one half.
+	pop := make([]Rule1D, p.pop)
+	for i := range pop {
+		l := rng.Float64()
+		for j := 0; j < 128; j++ {
+			pop[i].set(j, byte(btoi(rng.Float64() < l)))
+		}
+	}
+	var best Rule1D
+	for gen := 0; gen < p.gens; gen++ {
+		// Fresh configurations each generation, their densities spread
+		// evenly from 0 to 1, keep rules from fitting a particular set.
+		ics := make([][]byte, p.ics)
+		for i := range ics {
+			ics[i] = randomConfig(p.n, rng.Float64(), rng)
+		}
+		scores := t.score(pop, ics)
+		order := make([]int, len(pop))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+		mean := 0.0
+		for _, s := range scores {
+			mean += s
+		}
+		best = pop[order[0]]
+		if err := report(generationLog{gen, scores[order[0]], mean / float64(len(scores)), best}); err != nil {
+			return best, err
+		}
+
+		// The elite survive, and the rest are their offspring by
+		// single-point crossover of two elite rules, then mutation.
+		next := make([]Rule1D, 0, p.pop)
+		for _, i := range order[:p.elite] {
+			next = append(next, pop[i])
+		}
+		for len(next) < p.pop {
+			a, b := pop[order[rng.Intn(p.elite)]], pop[order[rng.Intn(p.elite)]]
+			cut := 1 + rng.Intn(127)
+			for _, parents := range [2][2]Rule1D{{a, b}, {b, a}} {
+				var child Rule1D
+				for j := 0; j < 128; j++ {
+					child.set(j, parents[btoi(j >= cut)].bit(j))
+				}
+				for _, j := range rng.Perm(128)[:p.mutations] {
+					child.flip(j)
+				}
+				if len(next) < p.pop {
+					next = append(next, child)
+				}
+			}
+		}
+		pop = next
+	}
+	return best, nil
+}
+
+func runMajority(args []string) error {
+	var (
+		p        evolveParams
+		t        densityTask
+		seed     int64
+		perf     int
+		logName  string
+		evalRule string
+		quiet    bool
+	)
+	fs := flag.NewFlagSet("majority", flag.ExitOnError)
+	fs.IntVar(&p.n, "n", 149, "ring size; must be odd")
+	fs.IntVar(&p.pop, "pop", 100, "rules per generation")
+	fs.IntVar(&p.elite, "elite", 20, "fittest rules kept unchanged each generation")
+	fs.IntVar(&p.ics, "ics", 100, "initial configurations each generation's fitness is measured on")
+	fs.IntVar(&p.gens, "gens", 100, "generations to evolve")
+	fs.IntVar(&p.mutations, "mutations", 2, "bits flipped in each offspring")
+	fs.IntVar(&t.steps, "steps", 0, "steps a rule has to classify a configuration (0 for twice the ring size)")
+	fs.IntVar(&t.workers, "workers", runtime.NumCPU(), "goroutines measuring fitness")
+	fs.Int64Var(&seed, "seed", 0, "random seed (0 picks one from the clock)")
+	fs.IntVar(&perf, "perf", 10000, "unbiased configurations the final performance is measured on")
+	fs.StringVar(&logName, "log", "", "write each generation's fitness to this CSV file")
+	fs.StringVar(&evalRule, "eval", "", "only measure the performance of this rule, in hexadecimal or gkl")
+	fs.BoolVar(&quiet, "q", false, "print only the result, not every generation")
+	fs.Parse(args)
+	if p.n < 7 || p.n%2 == 0 {
+		return errors.New("ring size must be odd and at least 7")
+	}
+	if p.pop < 2 || p.elite < 1 || p.elite > p.pop || p.ics < 1 || p.gens < 1 || p.mutations < 0 || p.mutations > 128 {
+		return errors.New("population, elite, configurations, generations or mutations out of range")
+	}
+	if perf < 1 {
+		return errors.New("performance must be measured on at least one configuration")
+	}
+	if t.steps == 0 {
+		t.steps = 2 * p.n
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	if evalRule != "" {
+		r, err := ParseRule1D(evalRule)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rule %s (λ=%.3f): performance %.4f on %d configurations of %d cells, seed %d\n",
+			r, r.lambda(), t.performance(r, p.n, perf, rng), perf, p.n, seed)
+		return nil
+	}
+
+	var (
+		log  *csv.Writer
+		logf *os.File
+	)
+	if logName != "" {
+		f, err := os.Create(logName)
+		if err != nil {
+			return err
+		}
+		logf = f
+		log = csv.NewWriter(f)
+		log.Write([]string{"generation", "best", "mean", "lambda", "rule"})
+	}
+	fmt.Printf("evolving %d rules for %d generations, seed %d\n", p.pop, p.gens, seed)
+	start := time.Now()
+	best, err := evolve(p, t, rng, func(g generationLog) error {
+		if !quiet {
+			fmt.Printf("gen %3d: best %.3f mean %.3f λ=%.3f %s\n", g.gen, g.best, g.mean, g.rule.lambda(), g.rule)
+		}
+		if log == nil {
+			return nil
+		}
+		log.Write([]string{strconv.Itoa(g.gen), strconv.FormatFloat(g.best, 'f', 4, 64),
+			strconv.FormatFloat(g.mean, 'f', 4, 64), strconv.FormatFloat(g.rule.lambda(), 'f', 4, 64), g.rule.String()})
+		log.Flush()
+		return log.Error()
+	})
+	if logf != nil {
+		if cerr := logf.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("evolved in %v\n", time.Since(start).Round(time.Millisecond))
+	// Both rules are measured on the same configurations.
+	perfSeed := rng.Int63()
+	fmt.Printf("best rule %s (λ=%.3f): performance %.4f\n",
+		best, best.lambda(), t.performance(best, p.n, perf, rand.New(rand.NewSource(perfSeed))))
+	fmt.Printf("GKL rule  %s (λ=%.3f): performance %.4f\n",
+		gklRule(), gklRule().lambda(), t.performance(gklRule(), p.n, perf, rand.New(rand.NewSource(perfSeed))))
+	return nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// NBT tag types.
+const (
+	tagEnd byte = iota
+	tagByte
+	tagShort
+	tagInt
+	tagLong
+	tagFloat
+	tagDouble
+	tagByteArray
+	tagString
+	tagList
+	tagCompound
+	tagIntArray
+	tagLongArray
+)
+
+// NBTCompound is an NBT compound tag. Its fields are kept in order so that
+// files are written deterministically.
+//
+// Field values are int8, int16, int32, int64, float32, float64, []byte,
+// string, NBTList, NBTCompound, []int32 or []int64, for the tag types in
+// that order.
+type NBTCompound []NBTField
+
+// NBTField is a named value in a compound tag.
+type NBTField struct {
+	Name  string
+	Value interface{}
+}
+
+// Get returns the value of the named field, or nil if there is none.
+func (c NBTCompound) Get(name string) interface{} {
+	for _, f := range c {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// NBTList is an NBT list tag: a sequence of unnamed values of a single type.
+type NBTList struct {
+	Type  byte
+	Items []interface{}
+}
+
+// WriteNBT writes c to w as an uncompressed named root compound.
+func WriteNBT(w io.Writer, name string, c NBTCompound) error {
+	bw := bufio.NewWriter(w)
+	e := &nbtEncoder{w: bw}
+	e.byte(tagCompound)
+	e.string(name)
+	e.payload(c)
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// nbtEncoder writes big-endian NBT payloads, remembering the first error.
+type nbtEncoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *nbtEncoder) write(v interface{}) {
+	if e.err == nil {
+		e.err = binary.Write(e.w, binary.BigEndian, v)
+	}
+}
+
+func (e *nbtEncoder) byte(b byte) { e.write(b) }
+
+func (e *nbtEncoder) string(s string) {
+	if len(s) > math.MaxUint16 {
+		e.err = errors.New("nbt: string too long")
+		return
+	}
+	e.write(uint16(len(s)))
+	e.write([]byte(s))
+}
+
+// tagType returns the NBT tag type of v.
+func tagType(v interface{}) (byte, error) {
+	switch v.(type) {
+	case int8:
+		return tagByte, nil
+	case int16:
+		return tagShort, nil
+	case int32:
+		return tagInt, nil
+	case int64:
+		return tagLong, nil
+	case float32:
+		return tagFloat, nil
+	case float64:
+		return tagDouble, nil
+	case []byte:
+		return tagByteArray, nil
+	case string:
+		return tagString, nil
+	case NBTList:
+		return tagList, nil
+	case NBTCompound:
+		return tagCompound, nil
+	case []int32:
+		return tagIntArray, nil
+	case []int64:
+		return tagLongArray, nil
+	}
+	return 0, fmt.Errorf("nbt: unsupported type %T", v)
+}
+
+func (e *nbtEncoder) payload(v interface{}) {
+	switch v := v.(type) {
+	case int8, int16, int32, int64, float32, float64:
+		e.write(v)
+	case []byte:
+		e.write(int32(len(v)))
+		e.write(v)
+	case string:
+		e.string(v)
+	case NBTList:
+		e.byte(v.Type)
+		e.write(int32(len(v.Items)))
+		for _, item := range v.Items {
+			if t, err := tagType(item); err != nil || t != v.Type {
+				e.err = fmt.Errorf("nbt: list of type %d holds %T", v.Type, item)
+				return
+			}
+			e.payload(item)
+		}
+	case NBTCompound:
+		for _, f := range v {
+			t, err := tagType(f.Value)
+			if err != nil {
+				e.err = err
+				return
+			}
+			e.byte(t)
+			e.string(f.Name)
+			e.payload(f.Value)
+		}
+		e.byte(tagEnd)
+	case []int32:
+		e.write(int32(len(v)))
+		e.write(v)
+	case []int64:
+		e.write(int32(len(v)))
+		e.write(v)
+	default:
+		e.err = fmt.Errorf("nbt: unsupported type %T", v)
+	}
+}
+
+// ReadNBT reads an uncompressed named root compound from r.
+func ReadNBT(r io.Reader) (name string, c NBTCompound, err error) {
+	d := &nbtDecoder{r: bufio.NewReader(r)}
+	if t := d.byte(); d.err == nil && t != tagCompound {
+		return "", nil, fmt.Errorf("nbt: root tag has type %d, want compound", t)
+	}
+	name = d.string()
+	v := d.payload(tagCompound, 0)
+	if d.err != nil {
+		return "", nil, d.err
+	}
+	return name, v.(NBTCompound), nil
+}
+
+// nbtDecoder reads big-endian NBT payloads, remembering the first error.
+type nbtDecoder struct {
+	r   io.Reader
+	err error
+}
+
+// maxNBTDepth bounds the nesting of lists and compounds.
+const maxNBTDepth = 512
+
+func (d *nbtDecoder) read(v interface{}) {
+	if d.err == nil {
+		d.err = binary.Read(d.r, binary.BigEndian, v)
+	}
+}
+
+func (d *nbtDecoder) byte() byte {
+	var b byte
+	d.read(&b)
+	return b
+}
+
+func (d *nbtDecoder) string() string {
+	var n uint16
+	d.read(&n)
+	b := d.bytes(int(n))
+	return string(b)
+}
+
+func (d *nbtDecoder) length() int {
+	var n int32
+	d.read(&n)
+	if n < 0 && d.err == nil {
+		d.err = errors.New("nbt: negative length")
+	}
+	if d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// bytes reads n bytes. The buffer grows as they arrive rather than being
+// allocated up front, so a corrupt length cannot claim more memory than the
+// input holds.
+func (d *nbtDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	var b bytes.Buffer
+	m, err := io.CopyN(&b, d.r, int64(n))
+	if m < int64(n) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil
+	}
+	return b.Bytes()
+}
+
+func (d *nbtDecoder) payload(t byte, depth int) interface{} {
+	if depth > maxNBTDepth {
+		d.err = errors.New("nbt: nested too deeply")
+	}
+	if d.err != nil {
+		return nil
+	}
+	switch t {
+	case tagByte:
+		var v int8
+		d.read(&v)
+		return v
+	case tagShort:
+		var v int16
+		d.read(&v)
+		return v
+	case tagInt:
+		var v int32
+		d.read(&v)
+		return v
+	case tagLong:
+		var v int64
+		d.read(&v)
+		return v
+	case tagFloat:
+		var v float32
+		d.read(&v)
+		return v
+	case tagDouble:
+		var v float64
+		d.read(&v)
+		return v
+	case tagByteArray:
+		return d.bytes(d.length())
+	case tagString:
+		return d.string()
+	case tagList:
+		l := NBTList{Type: d.byte()}
+		for n := d.length(); n > 0 && d.err == nil; n-- {
+			l.Items = append(l.Items, d.payload(l.Type, depth+1))
+		}
+		return l
+	case tagCompound:
+		c := NBTCompound{}
+		for {
+			ft := d.byte()
+			if d.err != nil || ft == tagEnd {
+				return c
+			}
+			name := d.string()
+			c = append(c, NBTField{name, d.payload(ft, depth+1)})
+		}
+	case tagIntArray:
+		var v []int32
+		for n := d.length(); n > 0 && d.err == nil; n-- {
+			var x int32
+			d.read(&x)
+			v = append(v, x)
+		}
+		return v
+	case tagLongArray:
+		var v []int64
+		for n := d.length(); n > 0 && d.err == nil; n-- {
+			var x int64
+			d.read(&x)
+			v = append(v, x)
+		}
+		return v
+	}
+	d.err = fmt.Errorf("nbt: unknown tag type %d", t)
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PatternMeta is descriptive information about a pattern.
+type PatternMeta struct {
+	Name       string
+	Author     string
+	Discovered string // date of discovery, free-form, such as "1970" or "2019-05-04"
+	Comments   []string
+	URLs       []string
+
+	// Gen is the generation the pattern was saved at, and X and Y are the
+	// coordinates of its board's top-left cell. Patterns run with HashLife
+	// can take all three beyond the range of an int64.
+	Gen  BigInt
+	X, Y BigInt
+}
+
+// Pattern is a Board together with the rule it runs under and its metadata.
+type Pattern struct {
+	PatternMeta
+	Board *Board
+	Rule  Rule
+}
+
+// isURL reports whether a comment line is a link to the pattern's source.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, "www.")
+}
+
+// parseComment files a free-form comment line under the metadata field it
+// describes. Formats without dedicated fields record metadata as "Key: value"
+// lines and links as bare URLs; anything else is a plain comment. A "Rule:"
+// line sets *rule.
+func (m *PatternMeta) parseComment(s string, rule *Rule) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if isURL(s) {
+		m.URLs = append(m.URLs, s)
+		return nil
+	}
+	if k, v, ok := strings.Cut(s, ":"); ok {
+		v = strings.TrimSpace(v)
+		switch k {
+		case "Name":
+			m.Name = v
+			return nil
+		case "Author":
+			m.Author = v
+			return nil
+		case "Discovered":
+			m.Discovered = v
+			return nil
+		case "Rule":
+			r, err := ParseRule(v)
+			if err != nil {
+				return err
+			}
+			*rule = r
+			return nil
+		case "Generation":
+			g, err := ParseBigInt(v)
+			if err != nil {
+				return fmt.Errorf("generation: %v", err)
+			}
+			m.Gen = g
+			return nil
+		case "Position":
+			x, y, err := parsePosition(v)
+			if err != nil {
+				return err
+			}
+			m.X, m.Y = x, y
+			return nil
+		}
+	}
+	m.Comments = append(m.Comments, s)
+	return nil
+}
+
+// parsePosition parses coordinates written as "x,y".
+func parsePosition(s string) (x, y BigInt, err error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if ok {
+		x, err = ParseBigInt(xs)
+	}
+	if ok && err == nil {
+		y, err = ParseBigInt(ys)
+	}
+	if !ok || err != nil {
+		return x, y, fmt.Errorf("malformed position %q", s)
+	}
+	return x, y, nil
+}
+
+// origin returns the coordinates of the top-left cell of the bounding box
+// of p's live cells, which is where the formats that crop to it place it.
+func (p *Pattern) origin() (x, y BigInt) {
+	bx, by, _, _ := p.Board.Bounds()
+	return p.X.Add(NewBigInt(int64(bx))), p.Y.Add(NewBigInt(int64(by)))
+}
+
+// placeLines returns the generation and the position of the cells cropped
+// to their bounding box as comment lines in the form parseComment reads,
+// for formats with no fields of their own for them. Zero values are left
+// out.
+func (p *Pattern) placeLines() []string {
+	var lines []string
+	if p.Gen.Sign() != 0 {
+		lines = append(lines, "Generation: "+p.Gen.String())
+	}
+	if x, y := p.origin(); x.Sign() != 0 || y.Sign() != 0 {
+		lines = append(lines, fmt.Sprintf("Position: %s,%s", x, y))
+	}
+	return lines
+}
+
+// commentLines returns the metadata as free-form comment lines in the form
+// parseComment reads. Name and author are included only if named is set;
+// the rule is included if it is not Conway's and withRule is set.
+func (p *Pattern) commentLines(named, withRule bool) []string {
+	var lines []string
+	if named && p.Name != "" {
+		lines = append(lines, "Name: "+p.Name)
+	}
+	if named && p.Author != "" {
+		lines = append(lines, "Author: "+p.Author)
+	}
+	if p.Discovered != "" {
+		lines = append(lines, "Discovered: "+p.Discovered)
+	}
+	if withRule && p.Rule != Conway {
+		lines = append(lines, "Rule: "+p.Rule.String())
+	}
+	lines = append(lines, p.Comments...)
+	return append(lines, p.URLs...)
+}
+
+// ReadPattern reads a pattern file, choosing the format by its extension:
+// .rle, .cells, .mc or .schem. Schematics are read back with live cells
+// made of the given block.
+func ReadPattern(name, live string) (*Pattern, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var p *Pattern
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".rle":
+		p, err = ParseRLE(string(b))
+	case ".cells":
+		p, err = ParseCells(string(b))
+	case ".mc":
+		p, err = ParseMacrocell(string(b))
+	case ".schem":
+		p, err = ParseSchematic(b, live)
+	default:
+		return nil, fmt.Errorf("%s: unknown pattern format", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return p, nil
+}
+
+// WritePattern writes p to a file in the format given by its extension, as
+// for ReadPattern. Schematics use the given blocks for live and dead cells.
+func WritePattern(name string, p *Pattern, live, dead string) error {
+	var data []byte
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".rle":
+		data = []byte(p.RLE())
+	case ".cells":
+		data = []byte(p.Cells())
+	case ".mc":
+		s, err := p.Macrocell()
+		if err != nil {
+			return err
+		}
+		data = []byte(s)
+	case ".schem":
+		s := &Schematic{Layers: []*Board{p.Board}, Live: live, Dead: dead, Meta: p.PatternMeta, Rule: p.Rule}
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := s.Encode(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	default:
+		return errors.New(name + ": unknown pattern format")
+	}
+	return os.WriteFile(name, data, 0o644)
+}
+
+func runConvert(args []string) error {
+	var (
+		in, out, name string
+		live, dead    string
+	)
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	fs.StringVar(&in, "in", "", "pattern file to read (.rle, .cells, .mc or .schem)")
+	fs.StringVar(&name, "pattern", "", "read this catalog pattern instead")
+	fs.StringVar(&out, "out", "", "pattern file to write, in the format given by its extension")
+	fs.StringVar(&live, "live", defaultLiveBlock, "schematic block for live cells")
+	fs.StringVar(&dead, "dead", defaultDeadBlock, "schematic block for dead cells")
+	fs.Parse(args)
+	if out == "" || (in == "") == (name == "") {
+		return errors.New("convert needs -out and one of -in or -pattern")
+	}
+	var p *Pattern
+	var err error
+	if in != "" {
+		p, err = ReadPattern(in, live)
+	} else {
+		p, err = catalogPattern(name)
+	}
+	if err != nil {
+		return err
+	}
+	if err := WritePattern(out, p, live, dead); err != nil {
+		return err
+	}
+	_, _, w, h := p.Board.Bounds()
+	fmt.Printf("wrote %s: %d×%d, rule %s\n", out, w, h, p.Rule)
+	return nil
+}
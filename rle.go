@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds returns the smallest rectangle containing every active cell as its
+// top-left corner and size. An empty field has zero size.
+func (f *Board) Bounds() (x, y, w, h int) {
+	x0, y0, x1, y1 := f.w, f.h, -1, -1
+	for y := 0; y < f.h; y++ {
+		for x := 0; x < f.w; x++ {
+			if f.s[y][x] {
+				if x < x0 {
+					x0 = x
+				}
+				if x > x1 {
+					x1 = x
+				}
+				if y < y0 {
+					y0 = y
+				}
+				y1 = y
+			}
+		}
+	}
+	if x1 < 0 {
+		return 0, 0, 0, 0
+	}
+	return x0, y0, x1 - x0 + 1, y1 - y0 + 1
+}
+
+// RLE returns the pattern in run-length encoded format, cropped to the
+// bounding box of its live cells. The name and author are written as #N and
+// #O lines and the rest of the metadata as #C lines, with the generation and
+// position on a #CXRLE line as Golly writes them.
+func (p *Pattern) RLE() string {
+	b := p.Board
+	bx, by, w, h := b.Bounds()
+	var out strings.Builder
+	if x, y := p.origin(); p.Gen.Sign() != 0 || x.Sign() != 0 || y.Sign() != 0 {
+		fmt.Fprintf(&out, "#CXRLE Pos=%s,%s Gen=%s\n", x, y, p.Gen)
+	}
+	if p.Name != "" {
+		fmt.Fprintf(&out, "#N %s\n", p.Name)
+	}
+	if p.Author != "" {
+		fmt.Fprintf(&out, "#O %s\n", p.Author)
+	}
+	for _, c := range p.commentLines(false, false) {
+		fmt.Fprintf(&out, "#C %s\n", c)
+	}
+	fmt.Fprintf(&out, "x = %d, y = %d, rule = %s\n", w, h, p.Rule)
+
+	// Runs are accumulated into items and wrapped at 70 columns. Runs of
+	// dead cells at the end of a row and of empty rows at the end of the
+	// pattern are dropped, and consecutive row ends are merged.
+	line := 0
+	emit := func(n int, tag byte) {
+		item := string(tag)
+		if n > 1 {
+			item = strconv.Itoa(n) + item
+		}
+		if line+len(item) > 70 {
+			out.WriteByte('\n')
+			line = 0
+		}
+		out.WriteString(item)
+		line += len(item)
+	}
+	rows := 0 // pending row ends
+	for y := by; y < by+h; y++ {
+		run, alive := 0, false
+		for x := bx; x < bx+w; x++ {
+			a := b.s[y][x]
+			if run > 0 && a != alive {
+				if rows > 0 {
+					emit(rows, '$')
+					rows = 0
+				}
+				emit(run, "bo"[btoi(alive)])
+				run = 0
+			}
+			alive = a
+			run++
+		}
+		if alive {
+			if rows > 0 {
+				emit(rows, '$')
+				rows = 0
+			}
+			emit(run, 'o')
+		}
+		rows++
+	}
+	emit(1, '!')
+	out.WriteByte('\n')
+	return out.String()
+}
+
+// maxPatternArea bounds the board a pattern file's header can ask for, as
+// the board is allocated before the cells are read.
+const maxPatternArea = 1 << 26
+
+// ParseRLE parses a pattern in run-length encoded format, on a board the
+// size given in its header. The rule is Conway's unless the header names
+// another. Metadata is read from #N (name), #O (author) and #C or #D
+// (comment) lines, and the generation and position from a #CXRLE line. In
+// multi-state patterns every state but the first is taken to be alive.
+func ParseRLE(s string) (*Pattern, error) {
+	p := &Pattern{Rule: Conway}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	i := 0
+	for ; i < len(lines) && (strings.HasPrefix(lines[i], "#") || strings.TrimSpace(lines[i]) == ""); i++ {
+		if err := p.parseRLEComment(lines[i]); err != nil {
+			return nil, err
+		}
+	}
+	if i == len(lines) {
+		return nil, errors.New("rle: missing header")
+	}
+	w, h := -1, -1
+	for _, f := range strings.Split(lines[i], ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("rle: malformed header %q", lines[i])
+		}
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		var err error
+		switch k {
+		case "x":
+			w, err = strconv.Atoi(v)
+		case "y":
+			h, err = strconv.Atoi(v)
+		case "rule":
+			p.Rule, err = ParseRule(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rle: %v", err)
+		}
+	}
+	if w < 0 || h < 0 {
+		return nil, fmt.Errorf("rle: header %q lacks a size", lines[i])
+	}
+	if w > 0 && h > maxPatternArea/w {
+		return nil, fmt.Errorf("rle: %d×%d pattern is too large", w, h)
+	}
+
+	b := NewBoard(w, h)
+	p.Board = b
+	x, y, n := 0, 0, 0
+	for _, line := range lines[i+1:] {
+		if strings.HasPrefix(line, "#") {
+			if err := p.parseRLEComment(line); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, c := range strings.TrimSpace(line) {
+			switch {
+			case c >= '0' && c <= '9':
+				// No run can be longer than the pattern is wide or high,
+				// which also keeps the count from overflowing.
+				n = n*10 + int(c-'0')
+				if n > max(w, h) {
+					return nil, fmt.Errorf("rle: run of more than %d cells exceeds the declared size", max(w, h))
+				}
+				continue
+			case c == '!':
+				return p, nil
+			case c == '$':
+				y += max(n, 1)
+				x = 0
+			case c == 'b' || c == '.':
+				x += max(n, 1)
+			case c == 'o' || c >= 'A' && c <= 'X':
+				for k := max(n, 1); k > 0; k-- {
+					if x >= w || y >= h {
+						return nil, errors.New("rle: pattern exceeds its declared size")
+					}
+					b.Set(x, y, true)
+					x++
+				}
+			case c == ' ' || c == '\t' || c == '\r':
+			default:
+				return nil, fmt.Errorf("rle: unexpected %q", c)
+			}
+			n = 0
+		}
+	}
+	return p, nil
+}
+
+// parseRLEComment files an RLE # line under the metadata it describes.
+func (p *Pattern) parseRLEComment(line string) error {
+	if len(line) < 2 {
+		return nil
+	}
+	text := strings.TrimSpace(line[2:])
+	switch line[1] {
+	case 'N':
+		p.Name = text
+	case 'O':
+		p.Author = text
+	case 'C', 'c', 'D':
+		if rest, ok := strings.CutPrefix(line, "#CXRLE"); ok {
+			return p.parseXRLE(rest)
+		}
+		return p.parseComment(text, &p.Rule)
+	case 'r':
+		r, err := ParseRule(text)
+		if err != nil {
+			return fmt.Errorf("rle: %v", err)
+		}
+		p.Rule = r
+	}
+	return nil
+}
+
+// parseXRLE reads the Pos and Gen fields of a #CXRLE line.
+func (p *Pattern) parseXRLE(s string) error {
+	for _, f := range strings.Fields(s) {
+		k, v, _ := strings.Cut(f, "=")
+		var err error
+		switch k {
+		case "Pos":
+			p.X, p.Y, err = parsePosition(v)
+		case "Gen":
+			p.Gen, err = ParseBigInt(v)
+		}
+		if err != nil {
+			return fmt.Errorf("rle: %v", err)
+		}
+	}
+	return nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
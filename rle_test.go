@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseRLE(t *testing.T) {
+	p, err := ParseRLE("#N Glider\nx = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewBoard(3, 3)
+	for _, c := range [][2]int{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}} {
+		want.Set(c[0], c[1], true)
+	}
+	if p.Name != "Glider" || !p.Board.Equal(want) {
+		t.Errorf("parsed %q as\n%s", p.Name, p.RLE())
+	}
+	q, err := ParseRLE(p.RLE())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Board.Equal(want) {
+		t.Errorf("round trip gave\n%s", q.RLE())
+	}
+}
+
+func TestParseRLEMalformed(t *testing.T) {
+	for _, s := range []string{
+		"x = 2, y = 2\n9223372036854775807b9223372036854775807bo!",
+		"x = 2, y = 2\n3o!",
+		"x = 2, y = 2\n3$o!",
+		"x = 100000, y = 100000\no!",
+		"x = 2\no!",
+		"x = 2, y = 2\nbq!",
+	} {
+		if _, err := ParseRLE(s); err == nil {
+			t.Errorf("ParseRLE(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule is a Life-like birth/survival rule. Bit n of Birth is set if a dead
+// cell with n live neighbors comes to life, and bit n of Survive is set if a
+// live cell with n live neighbors stays alive.
+type Rule struct {
+	Birth, Survive uint16
+}
+
+// Conway is the rule of Conway's Game of Life, B3/S23.
+var Conway = Rule{Birth: 1 << 3, Survive: 1<<2 | 1<<3}
+
+// namedRules are well-known Life-like rules, in the order the terminal UI
+// cycles through them.
+var namedRules = []struct {
+	name string
+	rule string
+}{
+	{"Life", "B3/S23"},
+	{"HighLife", "B36/S23"},
+	{"Day & Night", "B3678/S34678"},
+	{"Seeds", "B2/S"},
+	{"Life without Death", "B3/S012345678"},
+	{"2x2", "B36/S125"},
+}
+
+// ParseRule parses a rule in B/S notation, such as "B3/S23", or in the
+// older S/B notation used by many pattern files, such as "23/3". The letters
+// may be lower case.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) != 2 {
+		return r, fmt.Errorf("malformed rule %q", s)
+	}
+	birth, survive := parts[0], parts[1]
+	switch {
+	case strings.HasPrefix(birth, "B") && strings.HasPrefix(survive, "S"):
+		birth, survive = birth[1:], survive[1:]
+	case strings.HasPrefix(birth, "S") && strings.HasPrefix(survive, "B"):
+		birth, survive = survive[1:], birth[1:]
+	default:
+		// S/B notation without letters.
+		birth, survive = survive, birth
+	}
+	for i, p := range []string{birth, survive} {
+		mask := &r.Birth
+		if i == 1 {
+			mask = &r.Survive
+		}
+		for _, c := range p {
+			if c < '0' || c > '8' {
+				return r, fmt.Errorf("malformed rule %q", s)
+			}
+			*mask |= 1 << uint(c-'0')
+		}
+	}
+	return r, nil
+}
+
+// String returns the rule in B/S notation.
+func (r Rule) String() string {
+	var b strings.Builder
+	b.WriteByte('B')
+	for n := 0; n <= 8; n++ {
+		if r.Birth&(1<<n) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+	}
+	b.WriteString("/S")
+	for n := 0; n <= 8; n++ {
+		if r.Survive&(1<<n) != 0 {
+			b.WriteByte(byte('0' + n))
+		}
+	}
+	return b.String()
+}
+
+// Topology determines how the edges of a Board connect.
+type Topology int
+
+const (
+	Torus Topology = iota // edges wrap around, as in Board.Active
+	Plane                 // cells beyond the edges are always dead
+)
+
+func (t Topology) String() string {
+	if t == Plane {
+		return "plane"
+	}
+	return "torus"
+}
+
+// At reports whether the specified cell is active under topology t.
+func (f *Board) At(x, y int, t Topology) bool {
+	if t == Plane && (x < 0 || x >= f.w || y < 0 || y >= f.h) {
+		return false
+	}
+	return f.Active(x, y)
+}
+
+// NextRule returns the state of the specified cell at the next time step
+// under rule r and topology t.
+func (f *Board) NextRule(x, y int, r Rule, t Topology) bool {
+	// Count the adjacent cells that are active.
+	active := 0
+	for i := -1; i <= 1; i++ {
+		for j := -1; j <= 1; j++ {
+			if (j != 0 || i != 0) && f.At(x+i, y+j, t) {
+				active++
+			}
+		}
+	}
+	if f.Active(x, y) {
+		return r.Survive&(1<<active) != 0
+	}
+	return r.Birth&(1<<active) != 0
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// SandpileRule parameterizes the Abelian sandpile model.
+type SandpileRule struct {
+	Threshold int  // largest stable height; at least 3
+	Torus     bool // wrap grains around the edges instead of losing them
+	// Dissipation is the probability that a grain sent by a toppling cell
+	// is lost. On a torus nothing falls off the edges, so it must be
+	// positive for avalanches to end.
+	Dissipation float64
+	Fixed       bool // drop every grain at the center instead of at random
+}
+
+// Avalanche records the relaxation that followed a single grain drop.
+type Avalanche struct {
+	Size     int // number of topplings
+	Area     int // number of distinct cells that toppled
+	Duration int // number of parallel toppling rounds
+}
+
+// Sandpile is the Bak–Tang–Wiesenfeld Abelian sandpile. Grains are dropped one
+// at a time; a cell holding more than Threshold grains topples, sending one
+// grain to each of its four neighbors, until the whole field is stable again.
+type Sandpile struct {
+	z    []int // heights, row-major
+	w, h int
+	rule SandpileRule
+	r    *rand.Rand
+
+	cur, next  []int // toppling queues for the current and next round
+	mark       []int // round in which each cell was last queued
+	hit        []int // drop in which each cell last toppled
+	round      int   // rounds since the pile was created
+	drops      int   // grains dropped so far
+	Avalanches []Avalanche
+}
+
+// NewSandpile returns an empty sandpile of the given size.
+func NewSandpile(w, h int, rule SandpileRule, r *rand.Rand) *Sandpile {
+	return &Sandpile{
+		z: make([]int, w*h), w: w, h: h,
+		rule: rule, r: r,
+		mark: make([]int, w*h),
+		hit:  make([]int, w*h),
+	}
+}
+
+// Height returns the number of grains on the specified cell.
+func (p *Sandpile) Height(x, y int) int {
+	return p.z[y*p.w+x]
+}
+
+// Step drops a single grain and relaxes the pile, recording the avalanche
+// if any cell toppled.
+func (p *Sandpile) Step() {
+	x, y := p.w/2, p.h/2
+	if !p.rule.Fixed {
+		x, y = p.r.Intn(p.w), p.r.Intn(p.h)
+	}
+	p.Drop(x, y)
+}
+
+// Drop adds a grain to the specified cell and relaxes the pile.
+func (p *Sandpile) Drop(x, y int) {
+	p.drops++
+	i := y*p.w + x
+	p.z[i]++
+	if p.z[i] <= p.rule.Threshold {
+		return
+	}
+	var a Avalanche
+	p.cur = append(p.cur[:0], i)
+	for len(p.cur) > 0 {
+		p.round++
+		p.next = p.next[:0]
+		// A round counts towards the duration only if something in it
+		// toppled; every cell queued may have relaxed already.
+		toppled := false
+		for _, i := range p.cur {
+			if p.z[i] <= p.rule.Threshold {
+				continue
+			}
+			p.z[i] -= 4
+			a.Size++
+			toppled = true
+			if p.hit[i] != p.drops {
+				p.hit[i] = p.drops
+				a.Area++
+			}
+			p.scatter(i)
+			if p.z[i] > p.rule.Threshold {
+				p.enqueue(i)
+			}
+		}
+		if toppled {
+			a.Duration++
+		}
+		p.cur, p.next = p.next, p.cur
+	}
+	p.Avalanches = append(p.Avalanches, a)
+}
+
+// scatter sends one grain from cell i to each of its four neighbors.
+func (p *Sandpile) scatter(i int) {
+	x, y := i%p.w, i/p.w
+	for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+		nx, ny := x+d[0], y+d[1]
+		if p.rule.Torus {
+			nx = (nx + p.w) % p.w
+			ny = (ny + p.h) % p.h
+		} else if nx < 0 || nx >= p.w || ny < 0 || ny >= p.h {
+			continue // The grain falls off the edge.
+		}
+		if p.rule.Dissipation > 0 && p.r.Float64() < p.rule.Dissipation {
+			continue
+		}
+		j := ny*p.w + nx
+		p.z[j]++
+		if p.z[j] > p.rule.Threshold {
+			p.enqueue(j)
+		}
+	}
+}
+
+// enqueue schedules cell i to topple in the next round, at most once.
+func (p *Sandpile) enqueue(i int) {
+	if p.mark[i] != p.round {
+		p.mark[i] = p.round
+		p.next = append(p.next, i)
+	}
+}
+
+// String returns the field colored by height.
+func (p *Sandpile) String() string {
+	pal := huePalette(p.rule.Threshold + 2)
+	pal[0] = color.RGBA{0, 0, 0, 255}
+	return ansiCells(p.w, p.h, func(x, y int) color.RGBA {
+		z := p.Height(x, y)
+		if z >= len(pal) {
+			z = len(pal) - 1
+		}
+		return pal[z]
+	})
+}
+
+// WriteAvalanches writes the recorded avalanches to w as CSV, one row per
+// avalanche.
+func (p *Sandpile) WriteAvalanches(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"size", "area", "duration"})
+	for _, a := range p.Avalanches {
+		cw.Write([]string{
+			strconv.Itoa(a.Size),
+			strconv.Itoa(a.Area),
+			strconv.Itoa(a.Duration),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSizeHistogram writes the distribution of avalanche sizes to w as
+// formatted by writeLogHistogram.
+func (p *Sandpile) WriteSizeHistogram(w io.Writer) error {
+	sizes := make([]int, len(p.Avalanches))
+	for i, a := range p.Avalanches {
+		sizes[i] = a.Size
+	}
+	return writeLogHistogram(w, sizes)
+}
+
+func runSandpile(args []string) error {
+	var (
+		sf    simFlags
+		rule  SandpileRule
+		out   string
+		quiet bool
+	)
+	fs := flag.NewFlagSet("sandpile", flag.ExitOnError)
+	sf.register(fs)
+	fs.IntVar(&rule.Threshold, "threshold", 3, "largest stable height")
+	fs.BoolVar(&rule.Torus, "torus", false, "wrap grains around the edges")
+	fs.Float64Var(&rule.Dissipation, "dissipation", 0, "probability that a toppled grain is lost")
+	fs.BoolVar(&rule.Fixed, "fixed", false, "drop grains at the center instead of at random")
+	fs.StringVar(&out, "stats", "", "write avalanche sizes and durations to this CSV file")
+	fs.BoolVar(&quiet, "quiet", false, "do not draw the pile")
+	fs.Parse(args)
+	if rule.Threshold < 3 {
+		return fmt.Errorf("threshold must be at least 3, got %d", rule.Threshold)
+	}
+	if rule.Torus && rule.Dissipation <= 0 {
+		return errors.New("a toroidal sandpile needs positive dissipation")
+	}
+	p := NewSandpile(sf.w, sf.h, rule, sf.rand())
+	if quiet {
+		for i := 0; i < sf.n; i++ {
+			p.Step()
+		}
+	} else {
+		animate(p, sf.n, sf.delay)
+	}
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		if err := p.WriteAvalanches(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d drops, %d avalanches\n", p.drops, len(p.Avalanches))
+	return p.WriteSizeHistogram(os.Stdout)
+}
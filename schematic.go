@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// schematicDataVersion is the Minecraft data version recorded in exported
+// schematics: 2586, Java Edition 1.16.5.
+const schematicDataVersion = 2586
+
+// Default blocks for live and dead cells.
+const (
+	defaultLiveBlock = "minecraft:white_concrete"
+	defaultDeadBlock = "minecraft:air"
+)
+
+// Schematic is a stack of boards to be exported as a Sponge schematic
+// (version 2). Board x maps to the schematic's x axis, board y to its z
+// axis, and each board is one layer up the y axis, so a run of generations
+// builds upwards. The metadata and rule are kept in the schematic's
+// Metadata compound.
+type Schematic struct {
+	Layers     []*Board
+	Live, Dead string // block states for live and dead cells
+	Meta       PatternMeta
+	Rule       Rule
+}
+
+// Encode writes the schematic to w as gzip-compressed NBT.
+func (s *Schematic) Encode(w io.Writer) error {
+	if len(s.Layers) == 0 {
+		return errors.New("schematic has no layers")
+	}
+	if s.Live == s.Dead {
+		return errors.New("schematic live and dead blocks must differ")
+	}
+	wd, ln, ht := s.Layers[0].w, s.Layers[0].h, len(s.Layers)
+	if wd > 0xffff || ln > 0xffff || ht > 0xffff {
+		return errors.New("schematic is too large")
+	}
+	// The palette maps dead cells to 0 and live ones to 1; both indices fit
+	// in a single varint byte.
+	data := make([]byte, 0, wd*ln*ht)
+	for _, b := range s.Layers {
+		if b.w != wd || b.h != ln {
+			return errors.New("schematic layers differ in size")
+		}
+		for z := 0; z < ln; z++ {
+			for x := 0; x < wd; x++ {
+				v := byte(0)
+				if b.Active(x, z) {
+					v = 1
+				}
+				data = append(data, v)
+			}
+		}
+	}
+	root := NBTCompound{
+		{"Version", int32(2)},
+		{"DataVersion", int32(schematicDataVersion)},
+		{"Width", int16(uint16(wd))},
+		{"Height", int16(uint16(ht))},
+		{"Length", int16(uint16(ln))},
+		{"Offset", []int32{0, 0, 0}},
+		{"PaletteMax", int32(2)},
+		{"Palette", NBTCompound{
+			{s.Dead, int32(0)},
+			{s.Live, int32(1)},
+		}},
+		{"BlockData", data},
+		{"Metadata", s.metadata()},
+	}
+	zw := gzip.NewWriter(w)
+	if err := WriteNBT(zw, "Schematic", root); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// metadata returns the schematic's Metadata compound. Name and Author are
+// the standard Sponge fields; the rest are this program's own.
+func (s *Schematic) metadata() NBTCompound {
+	m := NBTCompound{}
+	str := func(name, v string) {
+		if v != "" {
+			m = append(m, NBTField{name, v})
+		}
+	}
+	strs := func(name string, v []string) {
+		if len(v) == 0 {
+			return
+		}
+		l := NBTList{Type: tagString}
+		for _, x := range v {
+			l.Items = append(l.Items, x)
+		}
+		m = append(m, NBTField{name, l})
+	}
+	str("Name", s.Meta.Name)
+	str("Author", s.Meta.Author)
+	str("Discovered", s.Meta.Discovered)
+	str("Rule", s.Rule.String())
+	strs("Comments", s.Meta.Comments)
+	strs("URLs", s.Meta.URLs)
+	if s.Meta.Gen.Sign() != 0 {
+		str("Generation", s.Meta.Gen.String())
+	}
+	if s.Meta.X.Sign() != 0 || s.Meta.Y.Sign() != 0 {
+		str("Position", s.Meta.X.String()+","+s.Meta.Y.String())
+	}
+	return m
+}
+
+// readMetadata sets the schematic's metadata and rule from m. The rule is
+// Conway's if m names none.
+func (s *Schematic) readMetadata(m NBTCompound) error {
+	str := func(name string) string {
+		v, _ := m.Get(name).(string)
+		return v
+	}
+	strs := func(name string) []string {
+		l, _ := m.Get(name).(NBTList)
+		var v []string
+		for _, x := range l.Items {
+			if x, ok := x.(string); ok {
+				v = append(v, x)
+			}
+		}
+		return v
+	}
+	s.Meta = PatternMeta{
+		Name:       str("Name"),
+		Author:     str("Author"),
+		Discovered: str("Discovered"),
+		Comments:   strs("Comments"),
+		URLs:       strs("URLs"),
+	}
+	s.Rule = Conway
+	if v := str("Rule"); v != "" {
+		r, err := ParseRule(v)
+		if err != nil {
+			return fmt.Errorf("schematic: %v", err)
+		}
+		s.Rule = r
+	}
+	if v := str("Generation"); v != "" {
+		g, err := ParseBigInt(v)
+		if err != nil {
+			return fmt.Errorf("schematic: generation: %v", err)
+		}
+		s.Meta.Gen = g
+	}
+	if v := str("Position"); v != "" {
+		x, y, err := parsePosition(v)
+		if err != nil {
+			return fmt.Errorf("schematic: %v", err)
+		}
+		s.Meta.X, s.Meta.Y = x, y
+	}
+	return nil
+}
+
+// maxSchematicVolume bounds the blocks ReadSchematic decodes, as the
+// dimensions in a file's header cannot be trusted.
+const maxSchematicVolume = 1 << 26
+
+// ReadSchematic reads a Sponge schematic written by Encode, decoding each
+// layer back into a Board in which cells of the block state live are active,
+// along with the metadata. Dead is set to the first other block in the
+// palette.
+func ReadSchematic(r io.Reader, live string) (*Schematic, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	_, root, err := ReadNBT(zr)
+	if err != nil {
+		return nil, err
+	}
+	wd, ok1 := root.Get("Width").(int16)
+	ht, ok2 := root.Get("Height").(int16)
+	ln, ok3 := root.Get("Length").(int16)
+	palette, ok4 := root.Get("Palette").(NBTCompound)
+	data, ok5 := root.Get("BlockData").([]byte)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		return nil, errors.New("schematic: missing or malformed fields")
+	}
+	w, h, l := int(uint16(wd)), int(uint16(ht)), int(uint16(ln))
+	// Every block takes at least one byte of block data, so the volume is
+	// checked against it as well as the cap before any layer is allocated.
+	if w*h*l > maxSchematicVolume {
+		return nil, fmt.Errorf("schematic: %d×%d×%d blocks is too large", w, h, l)
+	}
+	if w*h*l > len(data) {
+		return nil, errors.New("schematic: truncated block data")
+	}
+	s := &Schematic{Live: live}
+	liveIndex := int32(-1)
+	for _, f := range palette {
+		if f.Name == live {
+			v, ok := f.Value.(int32)
+			if !ok || v < 0 {
+				return nil, errors.New("schematic: malformed palette")
+			}
+			liveIndex = v
+		} else if s.Dead == "" {
+			s.Dead = f.Name
+		}
+	}
+	if liveIndex < 0 {
+		return nil, fmt.Errorf("schematic: block %q is not in the palette", live)
+	}
+	m, _ := root.Get("Metadata").(NBTCompound)
+	if err := s.readMetadata(m); err != nil {
+		return nil, err
+	}
+
+	layers := make([]*Board, h)
+	for y := range layers {
+		layers[y] = NewBoard(w, l)
+	}
+	// Block data is a sequence of varint palette indices in x, z, y order.
+	i := 0
+	for n := 0; n < w*h*l; n++ {
+		var v int32
+		for shift := uint(0); ; shift += 7 {
+			if i >= len(data) || shift > 28 {
+				return nil, errors.New("schematic: truncated block data")
+			}
+			b := data[i]
+			i++
+			v |= int32(b&0x7f) << shift
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		layers[n/(w*l)].Set(n%w, n/w%l, v == liveIndex)
+	}
+	s.Layers = layers
+	return s, nil
+}
+
+// ParseSchematic parses the bottom layer of a schematic file as a pattern,
+// with live cells made of the given block.
+func ParseSchematic(b []byte, live string) (*Pattern, error) {
+	s, err := ReadSchematic(bytes.NewReader(b), live)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Layers) == 0 {
+		return nil, errors.New("schematic has no layers")
+	}
+	return &Pattern{PatternMeta: s.Meta, Board: s.Layers[0], Rule: s.Rule}, nil
+}
+
+func runSchematic(args []string) error {
+	var (
+		sf     simFlags
+		out    string
+		layers int
+		s      Schematic
+	)
+	fs := flag.NewFlagSet("schem", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&out, "o", "life.schem", "output file")
+	fs.IntVar(&layers, "layers", 1, "number of generations to stack as layers")
+	fs.StringVar(&s.Live, "live", defaultLiveBlock, "block for live cells")
+	fs.StringVar(&s.Dead, "dead", defaultDeadBlock, "block for dead cells")
+	fs.Parse(args)
+	if layers < 1 {
+		return errors.New("layers must be positive")
+	}
+	l := NewStateRand(sf.w, sf.h, sf.rand())
+	s.Rule = l.rule
+	for i := 0; i < sf.n; i++ {
+		l.Step()
+	}
+	for i := 0; i < layers; i++ {
+		s.Layers = append(s.Layers, l.a.Copy())
+		l.Step()
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := s.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Read the file back to check that it round-trips.
+	f, err = os.Open(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	got, err := ReadSchematic(f, s.Live)
+	if err != nil {
+		return fmt.Errorf("reading back %s: %v", out, err)
+	}
+	if len(got.Layers) != len(s.Layers) {
+		return fmt.Errorf("reading back %s: got %d layers, want %d", out, len(got.Layers), len(s.Layers))
+	}
+	for i := range got.Layers {
+		if !got.Layers[i].Equal(s.Layers[i]) {
+			return fmt.Errorf("reading back %s: layer %d differs", out, i)
+		}
+	}
+	fmt.Printf("wrote %s: %d×%d×%d blocks\n", out, sf.w, layers, sf.h)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchematicRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	rule, err := ParseRule("B36/S23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Schematic{
+		Live: defaultLiveBlock,
+		Dead: defaultDeadBlock,
+		Rule: rule,
+		Meta: PatternMeta{
+			Name:     "soup",
+			Author:   "someone",
+			Comments: []string{"first", "second"},
+			Gen:      NewBigInt(42),
+			X:        NewBigInt(-3),
+			Y:        NewBigInt(7),
+		},
+	}
+	for i := 0; i < 3; i++ {
+		b := NewBoard(13, 9)
+		for y := 0; y < b.h; y++ {
+			for x := 0; x < b.w; x++ {
+				b.Set(x, y, rng.Intn(3) == 0)
+			}
+		}
+		s.Layers = append(s.Layers, b)
+	}
+	var buf bytes.Buffer
+	if err := s.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSchematic(bytes.NewReader(buf.Bytes()), s.Live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Layers) != len(s.Layers) {
+		t.Fatalf("got %d layers, want %d", len(got.Layers), len(s.Layers))
+	}
+	for i := range s.Layers {
+		if !got.Layers[i].Equal(s.Layers[i]) {
+			t.Errorf("layer %d differs", i)
+		}
+	}
+	if got.Dead != s.Dead {
+		t.Errorf("dead block %q, want %q", got.Dead, s.Dead)
+	}
+	if got.Rule.String() != s.Rule.String() {
+		t.Errorf("rule %v, want %v", got.Rule, s.Rule)
+	}
+	if !reflect.DeepEqual(got.Meta, s.Meta) {
+		t.Errorf("metadata %+v, want %+v", got.Meta, s.Meta)
+	}
+
+	if _, err := ReadSchematic(bytes.NewReader(buf.Bytes()), "minecraft:stone"); err == nil {
+		t.Error("reading with a block missing from the palette succeeded")
+	}
+}
+
+// gzipNBT returns root as a gzip-compressed NBT file.
+func gzipNBT(t *testing.T, root NBTCompound) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := WriteNBT(zw, "Schematic", root); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSchematicOversized(t *testing.T) {
+	// Header dimensions far larger than the block data must be rejected
+	// before the layers are allocated.
+	root := NBTCompound{
+		{"Width", int16(-1)},
+		{"Height", int16(-1)},
+		{"Length", int16(-1)},
+		{"Palette", NBTCompound{{defaultLiveBlock, int32(1)}}},
+		{"BlockData", []byte{1}},
+	}
+	if _, err := ReadSchematic(bytes.NewReader(gzipNBT(t, root)), defaultLiveBlock); err == nil {
+		t.Error("oversized schematic was accepted")
+	}
+
+	// So must a byte array whose length runs past the end of the input.
+	raw := []byte{tagCompound, 0, 0, tagByteArray, 0, 1, 'a', 0x7f, 0xff, 0xff, 0xff, 1, 2, 3}
+	if _, _, err := ReadNBT(bytes.NewReader(raw)); err == nil {
+		t.Error("truncated byte array was accepted")
+	}
+}
+
+func TestSchematicNotGzip(t *testing.T) {
+	if _, err := ReadSchematic(strings.NewReader("x = 1, y = 1\no!"), defaultLiveBlock); err == nil {
+		t.Error("an RLE file was read as a schematic")
+	}
+}
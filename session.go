@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// sessionHeader is the first line of a recorded terminal UI session: all
+// that is needed to start the UI again exactly as it started.
+type sessionHeader struct {
+	Seed   int64         `json:"seed"`
+	Width  int           `json:"width"` // board size
+	Height int           `json:"height"`
+	Screen [2]int        `json:"screen"` // terminal columns and rows
+	Delay  time.Duration `json:"delay"`
+	RLE    string        `json:"rle,omitempty"` // pattern opened in the first tab
+
+	Tutorial bool `json:"tutorial,omitempty"` // the first tab plays the tutorial
+}
+
+// sessionRecord is a line of a recording after the header: an input event
+// and when it happened, or the end of the session.
+type sessionRecord struct {
+	Tick   int64    `json:"tick"` // clock ticks before the event
+	Gen    int      `json:"gen"`  // generation of the tab shown at the time
+	Kind   string   `json:"kind"` // "key", "mouse", "resize" or "end"
+	Key    string   `json:"key,omitempty"`
+	X      int      `json:"x,omitempty"`
+	Y      int      `json:"y,omitempty"`
+	Hashes []uint64 `json:"hashes,omitempty"` // board hash of each tab, for "end"
+}
+
+// eventKinds names each EventKind in recordings.
+var eventKinds = [...]string{KeyEvent: "key", MouseEvent: "mouse", ResizeEvent: "resize"}
+
+// start returns a UI in the state the header describes.
+func (h *sessionHeader) start() (*ui, error) {
+	r := rand.New(rand.NewSource(h.Seed))
+	u := newUI(h.Screen[0], h.Screen[1], func() *State {
+		return NewStateRand(h.Width, h.Height, rand.New(rand.NewSource(r.Int63())))
+	})
+	if h.RLE != "" {
+		p, err := ParseRLE(h.RLE)
+		if err != nil {
+			return nil, err
+		}
+		u.load(p, h.Width, h.Height)
+	}
+	if h.Tutorial {
+		u.startLesson(0)
+	}
+	u.settle()
+	return u, nil
+}
+
+// end returns the record closing a session in the state u is in.
+func (u *ui) end() sessionRecord {
+	rec := sessionRecord{Tick: u.ticks, Gen: u.tab().l.gen, Kind: "end"}
+	for _, t := range u.tabs {
+		rec.Hashes = append(rec.Hashes, t.l.a.Hash())
+	}
+	return rec
+}
+
+// recorder writes a session to a file as it happens, one JSON object per
+// line. A nil recorder records nothing.
+type recorder struct {
+	f   *os.File
+	enc *json.Encoder
+	err error // first write error
+}
+
+// createRecorder starts a recording of the session described by h.
+func createRecorder(name string, h *sessionHeader) (*recorder, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{f: f, enc: json.NewEncoder(f)}
+	r.write(h)
+	return r, nil
+}
+
+func (r *recorder) write(v interface{}) {
+	if r.err == nil {
+		r.err = r.enc.Encode(v)
+	}
+}
+
+// event records ev, about to be handled by u.
+func (r *recorder) event(u *ui, ev Event) {
+	if r == nil {
+		return
+	}
+	r.write(sessionRecord{
+		Tick: u.ticks, Gen: u.tab().l.gen, Kind: eventKinds[ev.Kind],
+		Key: ev.Key, X: ev.X, Y: ev.Y,
+	})
+}
+
+// close records the end of the session and closes the file.
+func (r *recorder) close(u *ui) error {
+	if r == nil {
+		return nil
+	}
+	r.write(u.end())
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// replaySession replays the recording in the named file, checking that it
+// ends with the boards it ended with when recorded, and returns the UI in
+// its final state. If show is set, the replay is drawn on the terminal at
+// the recorded speed.
+func replaySession(name string, show bool) (*ui, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	var h sessionHeader
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	u, err := h.start()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	draw := func() {}
+	if show {
+		fmt.Print("\x1b[?1049h\x1b[?25l")
+		defer fmt.Print("\x1b[?25h\x1b[?1049l")
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		draw = func() { os.Stdout.WriteString(u.draw()) }
+	}
+	draw()
+	for {
+		var rec sessionRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil, fmt.Errorf("%s: recording has no end", name)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for u.ticks < rec.Tick {
+			if show {
+				select {
+				case <-time.After(h.Delay):
+				case <-interrupt:
+					return nil, errors.New("replay interrupted")
+				}
+			}
+			u.tick(h.Delay)
+			draw()
+		}
+		if g := u.tab().l.gen; g != rec.Gen {
+			return nil, fmt.Errorf("%s: replay diverged at tick %d: generation %d, recorded %d", name, rec.Tick, g, rec.Gen)
+		}
+		ev := Event{Key: rec.Key, X: rec.X, Y: rec.Y}
+		switch rec.Kind {
+		case "key":
+			ev.Kind = KeyEvent
+		case "mouse":
+			ev.Kind = MouseEvent
+		case "resize":
+			ev.Kind = ResizeEvent
+		case "end":
+			got := u.end()
+			if len(got.Hashes) != len(rec.Hashes) {
+				return nil, fmt.Errorf("%s: replay ended with %d tabs, recorded %d", name, len(got.Hashes), len(rec.Hashes))
+			}
+			for i := range got.Hashes {
+				if got.Hashes[i] != rec.Hashes[i] {
+					return nil, fmt.Errorf("%s: replay ended with a different board in tab %d", name, i+1)
+				}
+			}
+			return u, nil
+		default:
+			return nil, fmt.Errorf("%s: unknown record kind %q", name, rec.Kind)
+		}
+		u.handle(ev)
+		draw()
+	}
+}
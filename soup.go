@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Hash returns a hash of the field's cells.
+func (f *Board) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [1]byte
+	for _, row := range f.s {
+		for i, v := range row {
+			buf[0] = buf[0]<<1 | byte(btoi(v))
+			if i%8 == 7 {
+				h.Write(buf[:])
+				buf[0] = 0
+			}
+		}
+		h.Write(buf[:])
+		buf[0] = 0
+	}
+	return h.Sum64()
+}
+
+// Settle steps l until its board repeats an earlier one or limit generations
+// have passed. It returns the generation at which the repeat was seen and
+// the period of the cycle, or a period of 0 if the board never repeated.
+// Distinct boards may collide in the hash, so the period is very rarely
+// wrong.
+func Settle(l *State, limit int) (gen, period int) {
+	seen := map[uint64]int{l.a.Hash(): l.gen}
+	for l.gen < limit {
+		l.Step()
+		h := l.a.Hash()
+		if g, ok := seen[h]; ok {
+			return l.gen, l.gen - g
+		}
+		seen[h] = l.gen
+	}
+	return l.gen, 0
+}
+
+func runSoup(args []string) error {
+	var (
+		sf    simFlags
+		wf    webhookFlags
+		soups int
+		limit int
+		rare  int
+		rule  string
+		out   string
+		bs    bool
+	)
+	fs := flag.NewFlagSet("soup", flag.ExitOnError)
+	sf.register(fs)
+	wf.register(fs)
+	fs.IntVar(&soups, "soups", 1000, "number of soups to search")
+	fs.IntVar(&limit, "limit", 10000, "generations after which a soup is abandoned")
+	fs.IntVar(&rare, "rare", 3, "report soups that settle with at least this period")
+	fs.StringVar(&rule, "rule", "B3/S23", "rule in B/S notation")
+	fs.StringVar(&out, "findings", "", "append finds to this file, one JSON object per line")
+	fs.BoolVar(&bs, "bitsliced", false, "screen soups 64 at a time with the bit-sliced engine")
+	fs.Parse(args)
+	r, err := ParseRule(rule)
+	if err != nil {
+		return err
+	}
+	var findings *json.Encoder
+	if out != "" {
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		findings = json.NewEncoder(f)
+	}
+	sf.rand() // Pick a base seed if none was given.
+
+	report := func(seed int64, l *State, gen, period int) error {
+		object := fmt.Sprintf("p%d", period)
+		if period == 0 {
+			object = "unsettled"
+		}
+		fmt.Printf("seed %d: %s after %d generations\n", seed, object, gen)
+		p := &Pattern{
+			PatternMeta: PatternMeta{
+				Name:     fmt.Sprintf("%s from soup %d", object, seed),
+				Comments: []string{fmt.Sprintf("Found by soup search, seed %d, generation %d", seed, gen)},
+			},
+			Board: l.a, Rule: r,
+		}
+		find := WebhookPayload{
+			Event:      "find",
+			Object:     object,
+			Rule:       r.String(),
+			Seed:       seed,
+			RLE:        p.RLE(),
+			Generation: gen,
+			Time:       time.Now().UTC(),
+		}
+		if findings != nil {
+			if err := findings.Encode(find); err != nil {
+				return err
+			}
+		}
+		wf.notify(find)
+		return nil
+	}
+
+	// Soup i is seeded with seed+i, so any find can be reproduced alone
+	// with -soups 1.
+	start := time.Now()
+	for i := 0; i < soups; {
+		// The bit-sliced engine only screens the soups: those it does not
+		// see settle with a small period are taken out of it and run
+		// again alone, so the finds are the same either way.
+		batch := 1
+		var periods [64]int
+		var init *BitBoards
+		if bs {
+			batch = min(64, soups-i)
+			b := NewBitBoards(sf.w, sf.h, r)
+			for j := 0; j < batch; j++ {
+				b.Load(j, NewStateRand(sf.w, sf.h, rand.New(rand.NewSource(sf.seed+int64(i+j)))).a)
+			}
+			init = b.Copy()
+			periods = SettleBits(b, limit, ^uint64(0)>>(64-batch))
+		}
+		for j := 0; j < batch; j++ {
+			if bs && periods[j] != 0 && periods[j] < rare {
+				continue
+			}
+			seed := sf.seed + int64(i+j)
+			l := NewStateRand(sf.w, sf.h, rand.New(rand.NewSource(seed)))
+			if bs {
+				l.a = init.Board(j)
+			}
+			l.SetRule(r)
+			gen, period := Settle(l, limit)
+			if period < rare && period != 0 {
+				continue
+			}
+			if err := report(seed, l, gen, period); err != nil {
+				return err
+			}
+		}
+		i += batch
+	}
+	fmt.Printf("searched %d soups in %v\n", soups, time.Since(start).Round(time.Millisecond))
+	wf.notify(WebhookPayload{
+		Event: "complete",
+		Rule:  r.String(),
+		Seed:  sf.seed,
+		Count: soups,
+	})
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// writeLogHistogram writes the distribution of sizes to w in logarithmic
+// bins [2^k, 2^(k+1)), with each count normalized by its bin width and the
+// number of samples so that a power law appears as a straight line on a
+// log-log plot. Sizes below one are ignored.
+func writeLogHistogram(w io.Writer, sizes []int) error {
+	var bins []int
+	total := 0
+	for _, s := range sizes {
+		if s < 1 {
+			continue
+		}
+		k := 0
+		for ; s > 1; s >>= 1 {
+			k++
+		}
+		for len(bins) <= k {
+			bins = append(bins, 0)
+		}
+		bins[k]++
+		total++
+	}
+	if _, err := fmt.Fprintf(w, "size\tcount\tdensity\n"); err != nil {
+		return err
+	}
+	for k, n := range bins {
+		density := float64(n) / float64(int(1)<<k) / float64(total)
+		if _, err := fmt.Fprintf(w, "%d\t%d\t%g\n", 1<<k, n, density); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compartmental is a Model whose cells each belong to one of a fixed set of
+// compartments.
+type compartmental interface {
+	Model
+	// Census returns the number of cells in each compartment.
+	Census() []int
+	// Clusters returns the sizes of the connected clusters the model's
+	// statistics are concerned with.
+	Clusters() []int
+}
+
+// runCompartments runs m for sf.n generations, drawing it unless quiet is
+// set and writing the census of every generation, under the given column
+// names, to the CSV file out if it is not empty. It then prints statistics
+// on the sizes of m's clusters.
+func runCompartments(m compartmental, names []string, sf simFlags, out string, quiet bool) error {
+	var (
+		f  *os.File
+		cw *csv.Writer
+	)
+	if out != "" {
+		var err error
+		f, err = os.Create(out)
+		if err != nil {
+			return err
+		}
+		cw = csv.NewWriter(f)
+		cw.Write(append([]string{"generation"}, names...))
+	}
+	record := func(gen int) {
+		if cw == nil {
+			return
+		}
+		row := []string{strconv.Itoa(gen)}
+		for _, n := range m.Census() {
+			row = append(row, strconv.Itoa(n))
+		}
+		cw.Write(row)
+	}
+	record(0)
+	for i := 1; i <= sf.n; i++ {
+		m.Step()
+		record(i)
+		if !quiet {
+			fmt.Print("\x0c", m)
+			time.Sleep(sf.delay)
+		}
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	sizes := m.Clusters()
+	total, max := 0, 0
+	for _, s := range sizes {
+		total += s
+		if s > max {
+			max = s
+		}
+	}
+	mean := 0.0
+	if len(sizes) > 0 {
+		mean = float64(total) / float64(len(sizes))
+	}
+	fmt.Printf("%d clusters, mean size %.2f, largest %d\n", len(sizes), mean, max)
+	return writeLogHistogram(os.Stdout, sizes)
+}
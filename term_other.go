@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func rawTerminal() (restore func(), err error) {
+	return nil, errors.New("the terminal UI is not supported on this platform")
+}
+
+func terminalSize() (w, h int, err error) {
+	return 80, 24, nil
+}
+
+func notifyResize(c chan<- os.Signal) {}
+
+var terminateSignal = os.Interrupt
+
+func detachProcess(cmd *exec.Cmd) {}
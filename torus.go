@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// TorusView is a camera looking at a board wrapped onto a torus, the shape
+// Board.Active's wrapping really gives it: x runs around the ring and y
+// around the tube.
+type TorusView struct {
+	Yaw   float64 // rotation about the torus's axis, in radians
+	Pitch float64 // then tilt of the axis away from the viewer, in radians; 0 looks down it
+	Tube  float64 // radius of the tube as a fraction of the ring's
+}
+
+// defaultTorusView shows the torus tilted so that both the middle of the
+// board and the hole can be seen.
+var defaultTorusView = TorusView{Pitch: 1.0, Tube: 0.45}
+
+// torusLight is the direction toward the light, in view coordinates.
+var torusLight = normalize([3]float64{-0.4, 0.5, 0.8})
+
+// torusEye is the viewer's distance from the center of the torus, whose
+// ring has radius 1.
+const torusEye = 5.0
+
+func normalize(v [3]float64) [3]float64 {
+	n := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	return [3]float64{v[0] / n, v[1] / n, v[2] / n}
+}
+
+// rotate turns p from torus coordinates, with the axis along z, into view
+// coordinates: x to the right, y up and z toward the viewer.
+func (v TorusView) rotate(p [3]float64) [3]float64 {
+	cy, sy := math.Cos(v.Yaw), math.Sin(v.Yaw)
+	x, y, z := p[0]*cy-p[1]*sy, p[0]*sy+p[1]*cy, p[2]
+	cp, sp := math.Cos(v.Pitch), math.Sin(v.Pitch)
+	return [3]float64{x, y*cp + z*sp, z*cp - y*sp}
+}
+
+// torusVertex is a point of the surface projected onto the screen.
+type torusVertex struct {
+	x, y  float64 // screen position
+	z     float64 // depth, larger nearer
+	shade float64 // brightness from 0 to 1
+}
+
+// vertex returns the surface point at ring angle a and tube angle b,
+// projected in perspective onto a w×h screen whose pixels are aspect times
+// as tall as they are wide.
+func (v TorusView) vertex(a, b float64, w, h int, aspect float64) torusVertex {
+	ca, sa, cb, sb := math.Cos(a), math.Sin(a), math.Cos(b), math.Sin(b)
+	r := 1 + v.Tube*cb
+	p := v.rotate([3]float64{r * ca, r * sa, v.Tube * sb})
+	n := v.rotate([3]float64{cb * ca, cb * sa, sb})
+	light := max(0, n[0]*torusLight[0]+n[1]*torusLight[1]+n[2]*torusLight[2])
+
+	// Scale so that the torus fits however it is turned.
+	extent := (1 + v.Tube) * torusEye / (torusEye - 1 - v.Tube)
+	scale := 0.48 * min(float64(w), float64(h)*aspect) / extent
+	f := scale * torusEye / (torusEye - p[2])
+	return torusVertex{
+		x:     float64(w)/2 + p[0]*f,
+		y:     float64(h)/2 - p[1]*f/aspect,
+		z:     p[2],
+		shade: 0.2 + 0.8*light,
+	}
+}
+
+// raster draws b onto the torus on a w×h screen whose pixels are aspect
+// times as tall as they are wide, calling plot for every pixel of the
+// surface nearer than any drawn before it. Each cell is split into smaller
+// quads so that the surface is smooth even when the board is small.
+func (v TorusView) raster(b *Board, w, h int, aspect float64, plot func(x, y int, alive bool, shade float64)) {
+	if b.w == 0 || b.h == 0 || w == 0 || h == 0 {
+		return
+	}
+	sx, sy := max(1, (96+b.w-1)/b.w), max(1, (48+b.h-1)/b.h)
+	gw, gh := b.w*sx, b.h*sy
+	verts := make([]torusVertex, gw*gh)
+	// The middle of the board faces the viewer on the outside of the ring
+	// before any rotation, and the tube angle runs downward there so that
+	// the board is not mirrored.
+	for j := 0; j < gh; j++ {
+		for i := 0; i < gw; i++ {
+			ring := 2*math.Pi*float64(i)/float64(gw) + math.Pi/2
+			tube := math.Pi - 2*math.Pi*float64(j)/float64(gh)
+			verts[j*gw+i] = v.vertex(ring, tube, w, h, aspect)
+		}
+	}
+	depth := make([]float64, w*h)
+	for i := range depth {
+		depth[i] = math.Inf(-1)
+	}
+	tri := func(p0, p1, p2 torusVertex, alive bool) {
+		area := (p1.x-p0.x)*(p2.y-p0.y) - (p2.x-p0.x)*(p1.y-p0.y)
+		if area == 0 {
+			return
+		}
+		x0 := max(0, int(math.Floor(min(p0.x, p1.x, p2.x))))
+		x1 := min(w-1, int(math.Ceil(max(p0.x, p1.x, p2.x))))
+		y0 := max(0, int(math.Floor(min(p0.y, p1.y, p2.y))))
+		y1 := min(h-1, int(math.Ceil(max(p0.y, p1.y, p2.y))))
+		for y := y0; y <= y1; y++ {
+			py := float64(y) + 0.5
+			for x := x0; x <= x1; x++ {
+				px := float64(x) + 0.5
+				// Barycentric weights, all of the same sign as area inside.
+				w0 := ((p1.x-px)*(p2.y-py) - (p2.x-px)*(p1.y-py)) / area
+				w1 := ((p2.x-px)*(p0.y-py) - (p0.x-px)*(p2.y-py)) / area
+				w2 := 1 - w0 - w1
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+				z := w0*p0.z + w1*p1.z + w2*p2.z
+				if z <= depth[y*w+x] {
+					continue
+				}
+				depth[y*w+x] = z
+				plot(x, y, alive, w0*p0.shade+w1*p1.shade+w2*p2.shade)
+			}
+		}
+	}
+	for j := 0; j < gh; j++ {
+		for i := 0; i < gw; i++ {
+			i1, j1 := (i+1)%gw, (j+1)%gh
+			a, bb := verts[j*gw+i], verts[j*gw+i1]
+			c, d := verts[j1*gw+i], verts[j1*gw+i1]
+			alive := b.Active(i/sx, j/sy)
+			tri(a, bb, d, alive)
+			tri(a, d, c, alive)
+		}
+	}
+}
+
+// Image renders b on the torus as a w×h image in the colors of theme. Dead
+// cells are drawn partway between the background and live cells so that
+// the surface shows.
+func (v TorusView) Image(b *Board, w, h int, theme viewerTheme) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, theme.Background)
+		}
+	}
+	mix := func(a, b uint8, t float64) float64 {
+		return float64(a) + (float64(b)-float64(a))*t
+	}
+	bg, fg := theme.Background, theme.Alive
+	dead := [3]float64{mix(bg.R, fg.R, 0.25), mix(bg.G, fg.G, 0.25), mix(bg.B, fg.B, 0.25)}
+	alive := [3]float64{float64(fg.R), float64(fg.G), float64(fg.B)}
+	v.raster(b, w, h, 1, func(x, y int, a bool, shade float64) {
+		c := dead
+		if a {
+			c = alive
+		}
+		img.SetRGBA(x, y, color.RGBA{
+			uint8(min(255, c[0]*shade)), uint8(min(255, c[1]*shade)), uint8(min(255, c[2]*shade)), 255,
+		})
+	})
+	return img
+}
+
+// torusRamps are the characters for the surface from dark to bright, for
+// dead and live cells.
+var torusRamps = [2]string{".,-~:;=", "o*xO#%@"}
+
+// ASCII renders b on the torus as rows lines of cols characters, taking a
+// character to be twice as tall as it is wide.
+func (v TorusView) ASCII(b *Board, cols, rows int) []string {
+	screen := make([][]byte, rows)
+	for y := range screen {
+		screen[y] = []byte(strings.Repeat(" ", cols))
+	}
+	v.raster(b, cols, rows, 2, func(x, y int, alive bool, shade float64) {
+		ramp := torusRamps[btoi(alive)]
+		screen[y][x] = ramp[min(len(ramp)-1, int(shade*float64(len(ramp))))]
+	})
+	lines := make([]string, rows)
+	for y, row := range screen {
+		lines[y] = string(row)
+	}
+	return lines
+}
+
+// torusModel is a Model showing a game on a torus that turns a little with
+// every generation.
+type torusModel struct {
+	l          *State
+	view       TorusView
+	spin       float64 // radians of yaw per generation
+	cols, rows int
+}
+
+func (m *torusModel) Step() {
+	m.l.Step()
+	m.view.Yaw += m.spin
+}
+
+func (m *torusModel) String() string {
+	return strings.Join(m.view.ASCII(m.l.a, m.cols, m.rows), "\n") + "\n"
+}
+
+func runTorus(args []string) error {
+	var (
+		sf                     simFlags
+		file, name, out        string
+		size                   int
+		yaw, pitch, tube, spin float64
+		cols, rows             int
+		themeName              string
+	)
+	fs := flag.NewFlagSet("torus", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&file, "file", "", "start from the pattern in this .rle, .cells, .mc or .schem file")
+	fs.StringVar(&name, "pattern", "", "start from this catalog pattern")
+	fs.StringVar(&out, "png", "", "write the final generation to this PNG file instead of animating")
+	fs.IntVar(&size, "size", 512, "width and height of the PNG image")
+	fs.Float64Var(&yaw, "yaw", defaultTorusView.Yaw*180/math.Pi, "rotation about the torus's axis, in degrees")
+	fs.Float64Var(&pitch, "pitch", defaultTorusView.Pitch*180/math.Pi, "tilt of the torus's axis away from the viewer, in degrees; 0 looks down it")
+	fs.Float64Var(&tube, "tube", defaultTorusView.Tube, "tube radius as a fraction of the ring radius")
+	fs.Float64Var(&spin, "spin", 2, "degrees the torus turns each generation while animating")
+	fs.IntVar(&cols, "cols", 80, "width of the animation in characters")
+	fs.IntVar(&rows, "rows", 36, "height of the animation in characters")
+	fs.StringVar(&themeName, "theme", "night", "PNG colors: "+themeNames())
+	fs.Parse(args)
+	if tube <= 0 || tube >= 1 {
+		return errors.New("tube must be between 0 and 1")
+	}
+	if size < 1 || cols < 1 || rows < 1 {
+		return errors.New("image and animation sizes must be positive")
+	}
+	theme, ok := themeByName(themeName)
+	if !ok {
+		return fmt.Errorf("unknown theme %q", themeName)
+	}
+
+	l := NewStateRand(sf.w, sf.h, sf.rand())
+	var p *Pattern
+	var err error
+	switch {
+	case file != "":
+		p, err = ReadPattern(file, defaultLiveBlock)
+	case name != "":
+		p, err = catalogPattern(name)
+	}
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		// The pattern goes in the middle of an otherwise empty board.
+		l.a = NewBoard(sf.w, sf.h)
+		px, py := (sf.w-p.Board.w)/2, (sf.h-p.Board.h)/2
+		for y := 0; y < p.Board.h; y++ {
+			for x := 0; x < p.Board.w; x++ {
+				if p.Board.Active(x, y) {
+					l.a.Set(((px+x)%sf.w+sf.w)%sf.w, ((py+y)%sf.h+sf.h)%sf.h, true)
+				}
+			}
+		}
+		l.SetRule(p.Rule)
+	}
+
+	v := TorusView{Yaw: yaw * math.Pi / 180, Pitch: pitch * math.Pi / 180, Tube: tube}
+	if out == "" {
+		animate(&torusModel{l: l, view: v, spin: spin * math.Pi / 180, cols: cols, rows: rows}, sf.n, sf.delay)
+		return nil
+	}
+	for i := 0; i < sf.n; i++ {
+		l.Step()
+	}
+	if err := writePNG(out, v.Image(l.a, size, size, theme)); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s: generation %d\n", out, l.Generation())
+	return nil
+}
@@ -0,0 +1,653 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHistory is the number of earlier boards each tab keeps for undo.
+const maxHistory = 256
+
+// snapshot is a board saved in a tab's history.
+type snapshot struct {
+	b   *Board
+	gen int
+}
+
+// tab is one independent game in the terminal UI, with its own rule,
+// topology and history.
+type tab struct {
+	l       *State
+	rule    int // index into namedRules, or -1 for another rule
+	history []snapshot
+	running bool
+	cx, cy  int // cursor position on the board
+	ox, oy  int // board cell shown at the top left of the screen
+
+	// Display settings, set from a loaded pattern's LifeViewer script.
+	cw    int     // screen columns per cell, or 0 for one
+	gps   float64 // generations per second while running, or 0 for one per tick
+	due   float64 // generations owed at gps but not yet run
+	steps int     // generations per update, or 0 for one
+	theme string  // escape sequence coloring the board, or "" for none
+
+	torus bool      // show the board wrapped onto a torus instead of flat
+	view  TorusView // camera for the torus
+
+	dash analysis // what the dashboard shows about the game
+}
+
+// save pushes the current board onto the tab's history.
+func (t *tab) save() {
+	if len(t.history) == maxHistory {
+		t.history = append(t.history[:0], t.history[1:]...)
+	}
+	t.history = append(t.history, snapshot{t.l.a.Copy(), t.l.gen})
+}
+
+// cols returns the number of screen columns each cell takes.
+func (t *tab) cols() int {
+	return max(t.cw, 1)
+}
+
+// undo restores the most recently saved board, reporting whether there was
+// one.
+func (t *tab) undo() bool {
+	if len(t.history) == 0 {
+		return false
+	}
+	s := t.history[len(t.history)-1]
+	t.history = t.history[:len(t.history)-1]
+	t.l.a, t.l.gen = s.b, s.gen
+	return true
+}
+
+// ui is the state of the terminal UI: a set of tabs, the one being shown,
+// and a clipboard shared between them.
+type ui struct {
+	tabs      []*tab
+	cur       int
+	clip      *Board
+	selecting bool
+	ax, ay    int // selection anchor
+	w, h      int // terminal size
+	msg       string
+	quit      bool
+	newState  func() *State
+	ticks     int64                 // clock ticks so far
+	tut       *tutorial             // the tutorial being played, or nil
+	panels    [len(dashPanels)]bool // which dashboard panels are shown
+}
+
+// newUI returns a UI with a single tab. newState is called to create the
+// game for every new tab.
+func newUI(w, h int, newState func() *State) *ui {
+	u := &ui{w: w, h: h, newState: newState}
+	u.addTab()
+	return u
+}
+
+func (u *ui) tab() *tab { return u.tabs[u.cur] }
+
+// addTab opens a new tab and switches to it.
+func (u *ui) addTab() {
+	u.tabs = append(u.tabs, &tab{l: u.newState()})
+	u.cur = len(u.tabs) - 1
+	u.selecting = false
+}
+
+// selection returns the corners of the selected rectangle, inclusive.
+// Without a selection it is the cell under the cursor.
+func (u *ui) selection() (x0, y0, x1, y1 int) {
+	t := u.tab()
+	x0, y0, x1, y1 = t.cx, t.cy, t.cx, t.cy
+	if u.selecting {
+		if u.ax < x0 {
+			x0 = u.ax
+		} else {
+			x1 = u.ax
+		}
+		if u.ay < y0 {
+			y0 = u.ay
+		} else {
+			y1 = u.ay
+		}
+	}
+	return
+}
+
+// handle applies a single input event.
+func (u *ui) handle(ev Event) {
+	defer u.settle()
+	t := u.tab()
+	b := t.l.a
+	u.msg = ""
+	switch ev.Kind {
+	case ResizeEvent:
+		u.w, u.h = ev.X, ev.Y
+		return
+	case MouseEvent:
+		if t.torus {
+			return
+		}
+		// Only clicks on the part of the board on screen count.
+		lay := u.layout()
+		col, row := ev.X/t.cols(), ev.Y-lay.top
+		x, y := t.ox+col, t.oy+row
+		if ev.X >= 0 && row >= 0 && col < lay.vw && row < lay.vh && x < b.w && y < b.h {
+			t.cx, t.cy = x, y
+			t.save()
+			b.Set(x, y, !b.Active(x, y))
+		}
+		return
+	}
+	if t.torus && t.rotate(ev.Key) {
+		return
+	}
+	if u.inTutorial() && u.tutorialKey(ev.Key) {
+		return
+	}
+	switch ev.Key {
+	case "q", "ctrl-c":
+		u.quit = true
+	case " ":
+		t.running = !t.running
+	case "n", ".":
+		t.save()
+		t.l.Step()
+		t.dash.observe(t.l)
+	case "u":
+		if !t.undo() {
+			u.msg = "nothing to undo"
+		}
+	case "left", "h":
+		t.cx = (t.cx + b.w - 1) % b.w
+	case "right", "l":
+		t.cx = (t.cx + 1) % b.w
+	case "up", "k":
+		t.cy = (t.cy + b.h - 1) % b.h
+	case "down", "j":
+		t.cy = (t.cy + 1) % b.h
+	case "enter", "e":
+		t.save()
+		b.Set(t.cx, t.cy, !b.Active(t.cx, t.cy))
+	case "c":
+		t.save()
+		t.l.a = NewBoard(b.w, b.h)
+	case "v":
+		u.selecting = !u.selecting
+		u.ax, u.ay = t.cx, t.cy
+	case "y":
+		x0, y0, x1, y1 := u.selection()
+		u.clip = NewBoard(x1-x0+1, y1-y0+1)
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				u.clip.Set(x-x0, y-y0, b.Active(x, y))
+			}
+		}
+		u.selecting = false
+		u.msg = fmt.Sprintf("copied %d×%d", u.clip.w, u.clip.h)
+	case "p":
+		if u.clip == nil {
+			u.msg = "clipboard is empty"
+			break
+		}
+		// The clipboard is pasted with its top left at the cursor,
+		// wrapping around the edges of the board.
+		t.save()
+		for y := 0; y < u.clip.h; y++ {
+			for x := 0; x < u.clip.w; x++ {
+				b.Set((t.cx+x)%b.w, (t.cy+y)%b.h, u.clip.Active(x, y))
+			}
+		}
+	case "t":
+		u.addTab()
+	case "x":
+		if len(u.tabs) == 1 {
+			u.msg = "cannot close the last tab"
+			break
+		}
+		if u.inTutorial() {
+			u.tut = nil
+		}
+		u.tabs = append(u.tabs[:u.cur], u.tabs[u.cur+1:]...)
+		if u.cur == len(u.tabs) {
+			u.cur--
+		}
+		u.selecting = false
+	case "tab", "]":
+		u.cur = (u.cur + 1) % len(u.tabs)
+		u.selecting = false
+	case "[":
+		u.cur = (u.cur + len(u.tabs) - 1) % len(u.tabs)
+		u.selecting = false
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		if i, _ := strconv.Atoi(ev.Key); i <= len(u.tabs) {
+			u.cur = i - 1
+			u.selecting = false
+		}
+	case "r":
+		t.rule = (t.rule + 1) % len(namedRules)
+		r, _ := ParseRule(namedRules[t.rule].rule)
+		t.l.SetRule(r)
+	case "o":
+		t.l.SetTopology(1 - t.l.topo)
+	case "d":
+		t.torus = !t.torus
+		if t.view.Tube == 0 {
+			t.view = defaultTorusView
+		}
+	default:
+		u.togglePanel(ev.Key)
+	}
+}
+
+// torusTurn is the angle the torus view turns per key press.
+const torusTurn = math.Pi / 12
+
+// rotate turns the torus view for the movement keys, reporting whether key
+// was one of them.
+func (t *tab) rotate(key string) bool {
+	switch key {
+	case "left", "h":
+		t.view.Yaw -= torusTurn
+	case "right", "l":
+		t.view.Yaw += torusTurn
+	case "up", "k":
+		t.view.Pitch = max(t.view.Pitch-torusTurn, 0)
+	case "down", "j":
+		t.view.Pitch = min(t.view.Pitch+torusTurn, math.Pi)
+	default:
+		return false
+	}
+	return true
+}
+
+// tick advances every running tab by one update, dt after the last tick.
+// Tabs with a speed of their own run as many generations as are due.
+func (u *ui) tick(dt time.Duration) {
+	u.ticks++
+	for _, t := range u.tabs {
+		if !t.running {
+			continue
+		}
+		n := 1
+		if t.gps > 0 {
+			t.due += t.gps * dt.Seconds()
+			n = int(t.due)
+			t.due -= float64(n)
+		}
+		if n == 0 {
+			continue
+		}
+		t.save()
+		for i := n * max(t.steps, 1); i > 0; i-- {
+			t.l.Step()
+		}
+		t.dash.observe(t.l)
+	}
+	u.settle()
+}
+
+// settle brings the view up to date after the UI's state has changed: the
+// current tab's viewport follows its cursor, and its analysis observes the
+// board if the dashboard is shown. Keeping this out of draw leaves draw
+// free of side effects, so a replay matches the session however often
+// either of them drew.
+func (u *ui) settle() {
+	t := u.tab()
+	lay := u.layout()
+	if !t.torus {
+		t.follow(lay.vw, lay.vh)
+	}
+	if u.panels != [len(dashPanels)]bool{} {
+		t.dash.observe(t.l)
+	}
+}
+
+// load replaces the current tab's game with p, in the middle of a board at
+// least w×h, and applies the display settings in its LifeViewer script.
+func (u *ui) load(p *Pattern, w, h int) {
+	t := u.tab()
+	pb := p.Board
+	bw, bh := max(w, pb.w+2), max(h, pb.h+2)
+	l := NewState(bw, bh)
+	l.a = NewBoard(bw, bh)
+	px, py := (bw-pb.w)/2, (bh-pb.h)/2
+	for y := 0; y < pb.h; y++ {
+		for x := 0; x < pb.w; x++ {
+			l.a.Set(px+x, py+y, pb.Active(x, y))
+		}
+	}
+	l.SetRule(p.Rule)
+	*t = tab{l: l, rule: -1}
+	for i, nr := range namedRules {
+		if r, _ := ParseRule(nr.rule); r == p.Rule {
+			t.rule = i
+		}
+	}
+
+	v := p.Viewer()
+	t.running = v.running(false)
+	t.gps, t.steps = v.GPS, v.steps()
+	if v.Zoom >= 2 {
+		t.cw = 2
+	}
+	if v.Theme >= 0 {
+		th := v.theme()
+		t.theme = fmt.Sprintf("\x1b[38;2;%d;%d;%d;48;2;%d;%d;%dm",
+			th.Alive.R, th.Alive.G, th.Alive.B, th.Background.R, th.Background.G, th.Background.B)
+	}
+	// The cursor starts at the center of the view, which is centered on
+	// the pattern unless the script moves it.
+	t.cx = ((px+pb.w/2+int(math.Round(v.X)))%bw + bw) % bw
+	t.cy = ((py+pb.h/2+int(math.Round(v.Y)))%bh + bh) % bh
+	vw, vh := u.w/t.cols(), max(u.h-3, 1)
+	t.ox = max(0, min(t.cx-vw/2, bw-vw))
+	t.oy = max(0, min(t.cy-vh/2, bh-vh))
+}
+
+// follow scrolls the current tab so that the cursor is within a viewport
+// of vw×vh cells.
+func (t *tab) follow(vw, vh int) {
+	if t.cx < t.ox {
+		t.ox = t.cx
+	} else if t.cx >= t.ox+vw {
+		t.ox = t.cx - vw + 1
+	}
+	if t.cy < t.oy {
+		t.oy = t.cy
+	} else if t.cy >= t.oy+vh {
+		t.oy = t.cy - vh + 1
+	}
+}
+
+// tuiHelp summarizes the key bindings.
+const tuiHelp = "q:quit spc:run n:step u:undo ret:toggle v:select y:copy p:paste " +
+	"t:new tab x:close tab:next r:rule o:topology d:3d torus (arrows turn it) " +
+	"C/O/G/L:census/objects/graph/log"
+
+// tutorialHelp adds the tutorial's keys to tuiHelp while it is played.
+const tutorialHelp = " s:check a:again"
+
+// clipLine truncates s to the width of the terminal.
+func (u *ui) clipLine(s string) string {
+	if len(s) > u.w {
+		return s[:u.w]
+	}
+	return s
+}
+
+// layout is where draw puts the current tab's board: a viewport vw cells
+// wide and vh high, starting at screen row top, with any dashboard beside
+// it or in the below rows under it.
+type layout struct {
+	top, vw, vh int
+	side        bool
+	below       int
+}
+
+// layout returns where draw puts the board. The tab bar is above it, and
+// the status and help lines below.
+func (u *ui) layout() layout {
+	t := u.tab()
+	l := layout{top: 1, vw: u.w / t.cols(), vh: u.h - 3}
+	if u.inTutorial() {
+		// The lesson goes above the board, which gets what room is left.
+		n := len(u.tutorialLines())
+		l.top += n
+		l.vh -= n
+	}
+	l.vh = max(l.vh, 1)
+	// The dashboard goes beside the board if the terminal is wide enough,
+	// and otherwise below it.
+	if u.panels != [len(dashPanels)]bool{} {
+		if u.w >= minSideWidth {
+			l.side = true
+			l.vw = (u.w - sidePanelWidth - 1) / t.cols()
+		} else if l.vh > 1 {
+			l.below = l.vh / 2
+			l.vh -= l.below
+		}
+	}
+	return l
+}
+
+// draw returns the escape sequences that repaint the whole screen.
+func (u *ui) draw() string {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	for i, t := range u.tabs {
+		if i == u.cur {
+			buf.WriteString("\x1b[7m")
+		}
+		fmt.Fprintf(&buf, " %d:%s ", i+1, t.l.rule)
+		buf.WriteString("\x1b[0m")
+	}
+	buf.WriteString("\x1b[K\n")
+
+	t := u.tab()
+	b := t.l.a
+	cw := t.cols()
+	lay := u.layout()
+	vw, vh := lay.vw, lay.vh
+	help := tuiHelp
+	if u.inTutorial() {
+		for _, line := range u.tutorialLines() {
+			buf.WriteString(u.clipLine(line) + "\x1b[K\n")
+		}
+		help += tutorialHelp
+	}
+
+	var side, below []string
+	if lay.side {
+		side = u.dashboard(sidePanelWidth, vh)
+	} else if lay.below > 0 {
+		below = u.dashboard(u.w, lay.below)
+	}
+	var board bytes.Buffer
+	if t.torus {
+		for _, line := range t.view.ASCII(b, vw*cw, vh) {
+			board.WriteString(t.theme + line)
+			if t.theme != "" {
+				board.WriteString("\x1b[0m")
+			}
+			board.WriteString("\x1b[K\n")
+		}
+	} else {
+		u.drawBoard(&board, vw, vh)
+	}
+	if side == nil {
+		buf.Write(board.Bytes())
+	} else {
+		lines := strings.Split(board.String(), "\n")
+		for i := 0; i < vh; i++ {
+			if i < len(lines)-1 {
+				buf.WriteString(lines[i])
+			}
+			fmt.Fprintf(&buf, "\x1b[%dG%s\x1b[K\n", vw*cw+2, side[i])
+		}
+	}
+	for _, line := range below {
+		buf.WriteString(line + "\x1b[K\n")
+	}
+	buf.WriteString("\x1b[J")
+
+	state := "paused"
+	if t.running {
+		state = "running"
+	}
+	name := "custom"
+	if t.rule >= 0 {
+		name = namedRules[t.rule].name
+	}
+	status := fmt.Sprintf("gen %s  %s (%s)  %s  %s  %s",
+		NewBigInt(int64(t.l.gen)).Display(), t.l.rule, name, t.l.topo, state, u.msg)
+	buf.WriteString(u.clipLine(status) + "\x1b[K\n")
+	buf.WriteString(u.clipLine(help) + "\x1b[K")
+	return buf.String()
+}
+
+// drawBoard writes the part of the current tab's board that fits in vw×vh
+// cells, with the cursor and selection highlighted.
+func (u *ui) drawBoard(buf *bytes.Buffer, vw, vh int) {
+	t := u.tab()
+	b := t.l.a
+	cw := t.cols()
+	x0, y0, x1, y1 := u.selection()
+	for y := t.oy; y < t.oy+vh && y < b.h; y++ {
+		buf.WriteString(t.theme)
+		for x := t.ox; x < t.ox+vw && x < b.w; x++ {
+			c := []byte("  ")[:cw]
+			if b.Active(x, y) {
+				c = []byte("**")[:cw]
+			}
+			switch {
+			case x == t.cx && y == t.cy:
+				buf.WriteString("\x1b[7m")
+			case u.selecting && x >= x0 && x <= x1 && y >= y0 && y <= y1:
+				buf.WriteString("\x1b[44m")
+			default:
+				buf.Write(c)
+				continue
+			}
+			buf.Write(c)
+			buf.WriteString("\x1b[0m" + t.theme)
+		}
+		if t.theme != "" {
+			buf.WriteString("\x1b[0m")
+		}
+		buf.WriteString("\x1b[K\n")
+	}
+}
+
+func runTUI(args []string) error {
+	var (
+		sf             simFlags
+		file, name     string
+		record, replay string
+		check, tutor   bool
+		p              *Pattern
+		err            error
+	)
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	sf.register(fs)
+	fs.StringVar(&file, "file", "", "open the first tab on the pattern in this .rle, .cells, .mc or .schem file")
+	fs.StringVar(&name, "pattern", "", "open the first tab on this catalog pattern")
+	fs.StringVar(&record, "record", "", "record the session's input to this file")
+	fs.StringVar(&replay, "replay", "", "replay the session recorded in this file")
+	fs.BoolVar(&check, "check", false, "with -replay, only check that the replay ends with the recorded boards")
+	fs.BoolVar(&tutor, "tutorial", false, "play the tutorial's lessons on rules, still lifes, oscillators, gliders and guns")
+	fs.Parse(args)
+	if replay != "" {
+		u, err := replaySession(replay, !check)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("replayed %d ticks: %d tabs, boards identical\n", u.ticks, len(u.tabs))
+		return nil
+	}
+	// The delay is the period of the UI's clock, which must tick.
+	if sf.delay <= 0 {
+		return errors.New("delay must be positive")
+	}
+	switch {
+	case file != "":
+		p, err = ReadPattern(file, defaultLiveBlock)
+	case name != "":
+		p, err = catalogPattern(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	// Switch to the alternate screen, hide the cursor and report mouse
+	// presses; undo it all on the way out.
+	fmt.Print("\x1b[?1049h\x1b[?25l\x1b[?1000h\x1b[?1006h")
+	defer fmt.Print("\x1b[?1006l\x1b[?1000l\x1b[?25h\x1b[?1049l")
+
+	w, h, err := terminalSize()
+	if err != nil {
+		w, h = 80, 24
+	}
+	sf.rand()
+	hdr := &sessionHeader{Seed: sf.seed, Width: sf.w, Height: sf.h, Screen: [2]int{w, h}, Delay: sf.delay, Tutorial: tutor}
+	if p != nil {
+		// The pattern goes through RLE so that a replay opens exactly the
+		// same board.
+		hdr.RLE = p.RLE()
+	}
+	u, err := hdr.start()
+	if err != nil {
+		return err
+	}
+	var rec *recorder
+	if record != "" {
+		if rec, err = createRecorder(record, hdr); err != nil {
+			return err
+		}
+	}
+	uiLoop(u, sf.delay, rec)
+	return rec.close(u)
+}
+
+// uiLoop redraws u and feeds it input, resizes and clock ticks until it
+// quits. Input events are recorded by rec.
+func uiLoop(u *ui, delay time.Duration, rec *recorder) {
+	input := make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(input)
+				return
+			}
+			input <- buf[:n]
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(delay)
+	defer ticker.Stop()
+
+	for !u.quit {
+		os.Stdout.WriteString(u.draw())
+		select {
+		case b, ok := <-input:
+			if !ok {
+				return
+			}
+			for _, ev := range parseInput(b) {
+				rec.event(u, ev)
+				u.handle(ev)
+			}
+		case <-resize:
+			if w, h, err := terminalSize(); err == nil {
+				ev := Event{Kind: ResizeEvent, X: w, Y: h}
+				rec.event(u, ev)
+				u.handle(ev)
+			}
+		case <-ticker.C:
+			u.tick(delay)
+		case <-interrupt:
+			return
+		}
+	}
+}
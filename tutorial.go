@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lesson is one step of the terminal UI's tutorial: a prepared board, an
+// explanation ending in a task, and a check of the player's board that
+// runs it to see whether the task was done.
+type lesson struct {
+	title string
+	text  []string // explanation and task, shown above the board
+	w, h  int      // board size
+	cells string   // the prepared board in .cells form, row 0 first
+	x, y  int      // where the cursor starts
+	adds  int      // cells the player may add to the prepared board
+	// check returns "" if b, the prepared board with the player's cells
+	// added, does what the task asks, and otherwise a hint.
+	check func(b *Board) string
+}
+
+// board returns the lesson's prepared board. The cells literals begin with
+// a newline after the backquote, which is dropped; every row after it is
+// written out, dead ones as ".", so that the rows can be counted.
+func (ls *lesson) board() *Board {
+	b := NewBoard(ls.w, ls.h)
+	for y, row := range strings.Split(strings.TrimPrefix(ls.cells, "\n"), "\n") {
+		for x, c := range row {
+			if c == 'O' {
+				b.Set(x, y, true)
+			}
+		}
+	}
+	return b
+}
+
+// simulate returns b advanced n generations under Conway's rule on a
+// plane, as the lessons are played.
+func simulate(b *Board, n int) *Board {
+	l := &State{a: b.Copy(), b: NewBoard(b.w, b.h), w: b.w, h: b.h, rule: Conway, topo: Plane}
+	for i := 0; i < n; i++ {
+		l.Step()
+	}
+	return l.a
+}
+
+// population returns the number of live cells on b.
+func population(b *Board) int {
+	n := 0
+	for y := range b.s {
+		for _, a := range b.s[y] {
+			n += btoi(a)
+		}
+	}
+	return n
+}
+
+// padded returns b in the middle of a board with a margin of m cells on
+// every side, so that what it sends out can travel without hitting the edge.
+func padded(b *Board, m int) *Board {
+	p := NewBoard(b.w+2*m, b.h+2*m)
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			p.Set(x+m, y+m, b.Active(x, y))
+		}
+	}
+	return p
+}
+
+// lessons is the tutorial, in order.
+var lessons = []lesson{
+	{
+		title: "Birth",
+		text: []string{
+			"Each generation, every cell looks at its eight neighbors. A dead cell",
+			"with exactly three live neighbors is born. Move with the arrow keys and",
+			"press enter to toggle a cell. Task: add one cell so that the dead cell",
+			"under the cursor is born in the next generation.",
+		},
+		w: 11, h: 7, x: 5, y: 3, adds: 1,
+		cells: `
+.
+.
+.
+....O.O
+`,
+		check: func(b *Board) string {
+			if b.Active(5, 3) {
+				return "the cell under the cursor must start dead; give it neighbors instead"
+			}
+			if !simulate(b, 1).Active(5, 3) {
+				return "it needs exactly three live neighbors; press n to watch, then u to undo"
+			}
+			return ""
+		},
+	},
+	{
+		title: "Survival and death",
+		text: []string{
+			"A live cell with two or three live neighbors survives; with fewer it",
+			"dies of loneliness and with more of overcrowding. The blinker survives",
+			"forever by turning back and forth. Task: place one cell so that the",
+			"blinker dies out completely.",
+		},
+		w: 11, h: 7, x: 5, y: 2, adds: 1,
+		cells: `
+.
+.
+.
+....OOO
+`,
+		check: func(b *Board) string {
+			if population(simulate(b, 30)) != 0 {
+				return "something is still alive after 30 generations; try a cell diagonally off an end"
+			}
+			return ""
+		},
+	},
+	{
+		title: "Still lifes",
+		text: []string{
+			"A still life is a pattern in which every live cell survives and no",
+			"dead cell is born, so it never changes. The beehive is one, but this",
+			"one is missing a cell. Task: add one cell to make the pattern still.",
+		},
+		w: 10, h: 7, x: 3, y: 5, adds: 1,
+		cells: `
+.
+.
+.
+....OO
+...O..O
+....O
+`,
+		check: func(b *Board) string {
+			if !simulate(b, 1).Equal(b) {
+				return "the pattern still changes; press n to see where"
+			}
+			return ""
+		},
+	},
+	{
+		title: "Oscillators",
+		text: []string{
+			"An oscillator returns to its starting shape after a fixed number of",
+			"generations, its period. The blinker has period 2, and so does the",
+			"toad, which is missing a cell here. Task: add one cell so that the",
+			"pattern oscillates with period 2.",
+		},
+		w: 10, h: 7, x: 3, y: 3, adds: 1,
+		cells: `
+.
+.
+.
+....OO
+...OOO
+`,
+		check: func(b *Board) string {
+			if simulate(b, 1).Equal(b) || !simulate(b, 2).Equal(b) {
+				return "the pattern must change and then come back after two generations"
+			}
+			return ""
+		},
+	},
+	{
+		title: "Gliders",
+		text: []string{
+			"A spaceship returns to its shape after some generations, but moved.",
+			"The glider moves one cell diagonally every four generations. Task:",
+			"add one cell so that this pattern becomes a glider.",
+		},
+		w: 16, h: 12, x: 3, y: 3, adds: 1,
+		cells: `
+.
+.
+.
+....O
+.....O
+...OO
+`,
+		check: func(b *Board) string {
+			later := simulate(padded(b, 4), 4)
+			bx, by, w, h := b.Bounds()
+			lx, ly, lw, lh := later.Bounds()
+			if w != lw || h != lh || lx-4 == bx && ly-4 == by {
+				return "after four generations the pattern must have the same shape in a new place"
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if b.Active(bx+x, by+y) != later.Active(lx+x, ly+y) {
+						return "after four generations the pattern must have the same shape in a new place"
+					}
+				}
+			}
+			return ""
+		},
+	},
+	{
+		title: "Guns",
+		text: []string{
+			"A gun is an oscillator that sends out a spaceship every period, so it",
+			"grows forever. Bill Gosper's glider gun, found in 1970, fires a glider",
+			"every 30 generations, but this one has lost a cell. Task: put it back.",
+		},
+		w: 40, h: 14, x: 16, y: 8, adds: 1,
+		cells: `
+.
+.
+..........................O
+........................O.O
+..............OO......OO............OO
+.............O...O....OO............OO
+..OO........O.....O...OO
+..OO........O...O.OO....O.O
+..................O.......O
+.............O...O
+..............OO
+`,
+		check: func(b *Board) string {
+			// A working gun adds one five-cell glider every period.
+			b = simulate(padded(b, 50), 60)
+			if population(simulate(b, 120))-population(b) != 20 {
+				return "it does not fire a glider every 30 generations; press space to watch it"
+			}
+			return ""
+		},
+	},
+}
+
+// tutorial is the state of a tutorial being played in the terminal UI.
+type tutorial struct {
+	t      *tab   // the tab it is played in
+	i      int    // index of the lesson in lessons
+	start  *Board // the lesson's prepared board
+	passed bool
+}
+
+// startLesson opens lesson i in the current tab, under Life on a plane.
+func (u *ui) startLesson(i int) {
+	ls := &lessons[i]
+	t := u.tab()
+	l := NewState(ls.w, ls.h)
+	l.a = ls.board()
+	l.SetTopology(Plane)
+	*t = tab{l: l, cx: ls.x, cy: ls.y}
+	u.tut = &tutorial{t: t, i: i, start: l.a.Copy()}
+	u.selecting = false
+}
+
+// inTutorial reports whether the current tab is playing the tutorial.
+func (u *ui) inTutorial() bool {
+	return u.tut != nil && u.tut.t == u.tab()
+}
+
+// tutorialKey handles the tutorial's own keys, reporting whether key was
+// one of them: s checks the task and, once it is done, goes on to the next
+// lesson, and a starts the lesson again.
+func (u *ui) tutorialKey(key string) bool {
+	switch key {
+	case "a":
+		u.startLesson(u.tut.i)
+		return true
+	case "s":
+	default:
+		return false
+	}
+	if u.tut.passed {
+		if u.tut.i+1 == len(lessons) {
+			u.tut = nil
+			u.msg = "that was the last lesson: the board is yours"
+			return true
+		}
+		u.startLesson(u.tut.i + 1)
+		return true
+	}
+	ls := &lessons[u.tut.i]
+	if hint := u.tutorialRules(); hint != "" {
+		u.msg = hint
+		return true
+	}
+	if hint := ls.check(u.tab().l.a); hint != "" {
+		u.msg = "not yet: " + hint
+		return true
+	}
+	u.tut.passed = true
+	u.msg = "well done! press s for the next lesson"
+	return true
+}
+
+// tutorialRules returns a hint if the current board is not the lesson's
+// prepared board with at most the allowed number of cells added.
+func (u *ui) tutorialRules() string {
+	t := u.tab()
+	b, start := t.l.a, u.tut.start
+	if t.l.gen != 0 {
+		return "the check runs your starting board: press u to undo the steps or a to start over"
+	}
+	added := 0
+	for y := 0; y < b.h; y++ {
+		for x := 0; x < b.w; x++ {
+			switch a, s := b.Active(x, y), start.Active(x, y); {
+			case s && !a:
+				return "keep the cells you were given: press a to start over"
+			case a && !s:
+				added++
+			}
+		}
+	}
+	ls := &lessons[u.tut.i]
+	switch {
+	case added == 0:
+		return "add a cell first: move with the arrow keys and press enter"
+	case added > ls.adds:
+		return fmt.Sprintf("add only %d cell(s): press a to start over", ls.adds)
+	}
+	return ""
+}
+
+// tutorialLines returns the lines the tutorial shows above the board.
+func (u *ui) tutorialLines() []string {
+	ls := &lessons[u.tut.i]
+	lines := []string{fmt.Sprintf("Lesson %d of %d: %s", u.tut.i+1, len(lessons), ls.title)}
+	return append(lines, ls.text...)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLessons checks that every lesson's prepared board fits its size,
+// starts the cursor on a dead cell and does not already pass its check.
+func TestLessons(t *testing.T) {
+	for i := range lessons {
+		ls := &lessons[i]
+		rows := strings.Split(strings.TrimPrefix(ls.cells, "\n"), "\n")
+		if len(rows) > ls.h+1 { // the last row ends in a newline
+			t.Errorf("%s: %d rows on a board %d high", ls.title, len(rows)-1, ls.h)
+		}
+		for y, row := range rows {
+			if len(row) > ls.w {
+				t.Errorf("%s: row %d is %d cells on a board %d wide", ls.title, y, len(row), ls.w)
+			}
+		}
+		b := ls.board()
+		if ls.x < 0 || ls.x >= ls.w || ls.y < 0 || ls.y >= ls.h || b.Active(ls.x, ls.y) {
+			t.Errorf("%s: cursor at %d,%d is not on a dead cell of the board", ls.title, ls.x, ls.y)
+		}
+		if ls.check(b) == "" {
+			t.Errorf("%s: the prepared board already passes", ls.title)
+		}
+	}
+}
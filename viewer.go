@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ViewerSettings are the display settings a pattern asks for in a
+// LifeViewer script: commands between "[[" and "]]" in its comments, such as
+// "[[ ZOOM 8 GPS 10 THEME 6 X 5 Y 3 AUTOSTART ]]". A script may span several
+// comment lines.
+type ViewerSettings struct {
+	Script    bool    // whether the pattern has a script at all
+	Zoom      float64 // cell size in pixels, or 0 for the viewer's choice
+	X, Y      float64 // view center relative to the middle of the pattern, in cells
+	GPS       float64 // generations per second, or 0 for the viewer's speed
+	Step      int     // generations per update, or 0 for one
+	Theme     int     // index into viewerThemes, or -1 for the default
+	AutoStart bool    // start running as soon as the pattern is shown
+}
+
+// viewerTheme is a color scheme for the viewers.
+type viewerTheme struct {
+	Name              string
+	Background, Alive color.RGBA
+}
+
+// viewerThemes are the themes a script can select by number. They are this
+// program's own and only loosely follow LifeViewer's.
+var viewerThemes = []viewerTheme{
+	{"paper", color.RGBA{250, 250, 245, 255}, color.RGBA{20, 20, 40, 255}},
+	{"night", color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}},
+	{"phosphor", color.RGBA{0, 16, 0, 255}, color.RGBA{64, 255, 64, 255}},
+	{"amber", color.RGBA{24, 12, 0, 255}, color.RGBA{255, 176, 0, 255}},
+	{"ocean", color.RGBA{0, 24, 48, 255}, color.RGBA{96, 200, 255, 255}},
+	{"ember", color.RGBA{32, 0, 0, 255}, color.RGBA{255, 96, 32, 255}},
+	{"slate", color.RGBA{40, 44, 52, 255}, color.RGBA{230, 200, 120, 255}},
+	{"mint", color.RGBA{230, 250, 240, 255}, color.RGBA{0, 120, 90, 255}},
+	{"violet", color.RGBA{24, 0, 40, 255}, color.RGBA{220, 140, 255, 255}},
+	{"rose", color.RGBA{255, 240, 245, 255}, color.RGBA{190, 20, 90, 255}},
+}
+
+// theme returns the selected theme, or the first one if none is selected.
+func (v ViewerSettings) theme() viewerTheme {
+	if v.Theme < 0 || v.Theme >= len(viewerThemes) {
+		return viewerThemes[0]
+	}
+	return viewerThemes[v.Theme]
+}
+
+// themeByName returns the theme with the given name.
+func themeByName(name string) (viewerTheme, bool) {
+	for _, t := range viewerThemes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return viewerTheme{}, false
+}
+
+// themeNames returns the names of the themes, separated by commas.
+func themeNames() string {
+	var names []string
+	for _, t := range viewerThemes {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Viewer returns the settings in the pattern's LifeViewer script. Commands
+// this program does not use, and malformed arguments, are ignored, so that
+// the pattern can still be shown.
+func (m *PatternMeta) Viewer() ViewerSettings {
+	v := ViewerSettings{Theme: -1}
+	text := strings.Join(m.Comments, " ")
+	for {
+		i := strings.Index(text, "[[")
+		if i < 0 {
+			break
+		}
+		text = text[i+2:]
+		script := text
+		if j := strings.Index(text, "]]"); j >= 0 {
+			script, text = text[:j], text[j+2:]
+		} else {
+			text = ""
+		}
+		v.Script = true
+		v.parse(strings.Fields(script))
+	}
+	return v
+}
+
+// Scripts come from pattern files, so the speeds they ask for are capped to
+// keep a viewer responsive.
+const (
+	maxViewerGPS  = 1000
+	maxViewerStep = 1000
+)
+
+// parse applies a script's commands. Values out of range are ignored or,
+// for speeds, clamped.
+func (v *ViewerSettings) parse(f []string) {
+	num := func(i int) (float64, bool) {
+		if i >= len(f) {
+			return 0, false
+		}
+		x, err := strconv.ParseFloat(f[i], 64)
+		return x, err == nil
+	}
+	for i := 0; i < len(f); i++ {
+		switch strings.ToUpper(f[i]) {
+		case "ZOOM", "Z":
+			// Negative zooms are reciprocals: -2 is half a pixel per cell.
+			if z, ok := num(i + 1); ok {
+				i++
+				if z < 0 {
+					z = -1 / z
+				}
+				if z > 0 {
+					v.Zoom = z
+				}
+			}
+		case "X":
+			if x, ok := num(i + 1); ok {
+				i++
+				v.X = x
+			}
+		case "Y":
+			if y, ok := num(i + 1); ok {
+				i++
+				v.Y = y
+			}
+		case "GPS":
+			if g, ok := num(i + 1); ok {
+				i++
+				if g > 0 {
+					v.GPS = min(g, maxViewerGPS)
+				}
+			}
+		case "STEP":
+			if s, ok := num(i + 1); ok {
+				i++
+				if s >= 1 {
+					v.Step = int(min(s, maxViewerStep))
+				}
+			}
+		case "THEME":
+			if t, ok := num(i + 1); ok {
+				i++
+				if t >= 0 && int(t) < len(viewerThemes) {
+					v.Theme = int(t)
+				}
+			}
+		case "AUTOSTART":
+			v.AutoStart = true
+		}
+	}
+}
+
+// running reports whether a viewer should start running the pattern. With
+// no script the viewer keeps its own default, def.
+func (v ViewerSettings) running(def bool) bool {
+	if !v.Script {
+		return def
+	}
+	return v.AutoStart
+}
+
+// steps returns the number of generations per update.
+func (v ViewerSettings) steps() int {
+	return max(v.Step, 1)
+}
+
+// hexColor formats c for CSS.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
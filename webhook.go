@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// WebhookPayload is the JSON body posted to a webhook.
+type WebhookPayload struct {
+	Event      string    `json:"event"` // "find" or "complete"
+	Object     string    `json:"object,omitempty"`
+	Rule       string    `json:"rule"`
+	Seed       int64     `json:"seed"`
+	RLE        string    `json:"rle,omitempty"`
+	Generation int       `json:"generation,omitempty"`
+	Count      int       `json:"count,omitempty"` // soups searched, for "complete"
+	Time       time.Time `json:"time"`
+}
+
+// Webhook posts notifications to a URL, retrying failed deliveries.
+type Webhook struct {
+	URL     string
+	Retries int           // retries after the first attempt
+	Backoff time.Duration // delay before the first retry, doubled for each later one
+	Client  *http.Client
+}
+
+// statusError is returned for a delivery the receiver answered with an
+// unsuccessful HTTP status.
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("webhook: receiver responded %d %s", e.code, http.StatusText(e.code))
+}
+
+// retryable reports whether a failed delivery may succeed if tried again:
+// the request timed out, the connection was refused or dropped, as while a
+// receiver restarts, or the receiver was overloaded or broken. Malformed
+// URLs and unsupported schemes are permanent.
+func retryable(err error) bool {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= 500
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// Notify posts p to the webhook, retrying with exponential backoff.
+func (h *Webhook) Notify(p WebhookPayload) error {
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	delay := h.Backoff
+	for attempt := 0; ; attempt++ {
+		err = h.post(body)
+		if err == nil || attempt == h.Retries || !retryable(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (h *Webhook) post(body []byte) error {
+	c := h.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Post(h.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError{resp.StatusCode}
+	}
+	return nil
+}
+
+// webhookFlags holds the flags of commands that can send notifications.
+type webhookFlags struct {
+	Webhook
+}
+
+// register adds the webhook flags to fs.
+func (f *webhookFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.URL, "webhook", "", "POST notifications to this URL")
+	fs.IntVar(&f.Retries, "retries", 4, "webhook delivery retries")
+	fs.DurationVar(&f.Backoff, "backoff", time.Second, "delay before the first webhook retry")
+	f.Client = &http.Client{Timeout: 30 * time.Second}
+}
+
+// notify posts p if a webhook was configured, reporting failures on the
+// console rather than interrupting the run.
+func (f *webhookFlags) notify(p WebhookPayload) {
+	if f.URL == "" {
+		return
+	}
+	if err := f.Notify(p); err != nil {
+		fmt.Println("webhook:", err)
+	}
+}
+
+func runReceiver(args []string) error {
+	var (
+		addr string
+		fail int
+	)
+	fs := flag.NewFlagSet("receive", flag.ExitOnError)
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	fs.IntVar(&fail, "fail", 0, "answer the first n requests with 503, to exercise retries")
+	fs.Parse(args)
+	// Handlers run concurrently, so the count of requests left to fail is
+	// shared atomically.
+	var failing atomic.Int64
+	failing.Store(int64(fail))
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		if failing.Add(-1) >= 0 {
+			fmt.Println("failing request on purpose")
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var p WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("%s %s object=%q rule=%s seed=%d generation=%d count=%d\n%s",
+			p.Time.Format(time.RFC3339), p.Event, p.Object, p.Rule, p.Seed, p.Generation, p.Count, p.RLE)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	fmt.Println("listening on", addr)
+	return http.ListenAndServe(addr, nil)
+}